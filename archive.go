@@ -0,0 +1,147 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var (
+	restoreOngoingRegex = regexp.MustCompile(`ongoing-request="true"`)
+	restoreExpiryRegex  = regexp.MustCompile(`expiry-date="([^"]+)"`)
+)
+
+type RestoreStatus struct {
+	Key          string
+	StorageClass string
+	Ongoing      bool   // a restore has been requested and is running
+	Restored     bool   // a temporary copy is available to download
+	Expiry       string // when the temporary copy goes away
+}
+
+func (r RestoreStatus) String() string {
+
+	// Human readable restore state
+
+	switch {
+	case r.Restored:
+		return fmt.Sprintf("restored until %s", r.Expiry)
+	case r.Ongoing:
+		return "restore in progress"
+	default:
+		return "archived"
+	}
+}
+
+func IsArchivedStorageClass(class string) bool {
+
+	// Only these classes need a restore before they can be read.
+	// GLACIER_IR is instant retrieval and downloads like anything else.
+
+	return class == s3.ObjectStorageClassGlacier || class == s3.ObjectStorageClassDeepArchive
+}
+
+func IsArchivedNode(node *Node) bool {
+	return node.S3Object != nil && IsArchivedStorageClass(aws.StringValue(node.S3Object.StorageClass))
+}
+
+func GetArchivedNodes(node *Node) (nodes []*Node) {
+
+	// Collect every archived file at or under a node
+
+	for _, file := range GetFileNodes(node) {
+		if IsArchivedNode(file) {
+			nodes = append(nodes, file)
+		}
+	}
+	return
+}
+
+func GetRestoreTiers(class string) []string {
+
+	// Expedited retrievals aren't offered for Deep Archive
+
+	if class == s3.ObjectStorageClassDeepArchive {
+		return []string{s3.TierStandard, s3.TierBulk}
+	}
+	return []string{s3.TierStandard, s3.TierBulk, s3.TierExpedited}
+}
+
+func ParseRestoreHeader(header string) (ongoing bool, restored bool, expiry string) {
+
+	// The x-amz-restore header looks like:
+	//   ongoing-request="true"
+	//   ongoing-request="false", expiry-date="Fri, 21 Dec 2012 00:00:00 GMT"
+
+	if header == "" {
+		return
+	}
+	ongoing = restoreOngoingRegex.MatchString(header)
+	if match := restoreExpiryRegex.FindStringSubmatch(header); match != nil {
+		restored = !ongoing
+		expiry = match[1]
+	}
+	return
+}
+
+func (s S3Session) GetRestoreStatus(bucket BucketWithDisplay, key string) (status RestoreStatus, err error) {
+
+	// Check an object's storage class and restore state with HeadObject
+
+	log.Printf("Checking restore status of %s\n", key)
+	out, err := s.HeadObject(bucket, key)
+	if err != nil {
+		return
+	}
+	status.Key = key
+	status.StorageClass = aws.StringValue(out.StorageClass)
+	status.Ongoing, status.Restored, status.Expiry = ParseRestoreHeader(aws.StringValue(out.Restore))
+	return
+}
+
+func (s S3Session) RestoreArchivedObject(bucket BucketWithDisplay, key string, tier string, days int64) (err error) {
+
+	// Request a temporary copy of an archived object.
+	// Asking again while a restore is running is not an error.
+
+	log.Printf("Requesting %s restore of %s for %d days\n", tier, key, days)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	_, err = s.S3Service.RestoreObject(&s3.RestoreObjectInput{
+		Bucket: bucket.bucket.Name,
+		Key:    aws.String(key),
+		RestoreRequest: &s3.RestoreRequest{
+			Days: aws.Int64(days),
+			GlacierJobParameters: &s3.GlacierJobParameters{
+				Tier: aws.String(tier),
+			},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "RestoreAlreadyInProgress" {
+		log.Printf("Restore already in progress for %s\n", key)
+		err = nil
+	}
+	return
+}
@@ -0,0 +1,168 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gizak/termui"
+)
+
+func CheckArchivedNode(bucket BucketWithDisplay, node *Node, back func()) (ok bool) {
+
+	// Return true if a node can be read. Archived objects that haven't been
+	// restored get a prompt to start a restore instead.
+
+	if !IsArchivedNode(node) {
+		return true
+	}
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return false
+	}
+	status, err := sess.GetRestoreStatus(bucket, *node.S3Object.Key)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return false
+	}
+	if status.Restored {
+		return true
+	}
+	if status.Ongoing {
+		RenderError(fmt.Sprintf("%s is being restored from %s, try again later", node.DisplayString, status.StorageClass))
+		return false
+	}
+
+	msg := fmt.Sprintf("%s is in %s and must be restored before it can be read. Restore it?", node.DisplayString, status.StorageClass)
+	RenderConfirmPrompt("Archived Object", msg, func() {
+		RenderRestorePrompt(bucket, node, back)
+	}, back)
+	return false
+}
+
+func RenderRestorePrompt(bucket BucketWithDisplay, node *Node, back func()) {
+
+	// Pick a tier and a number of days, then restore every archived object
+	// at or under the node
+
+	nodes := GetArchivedNodes(node)
+	if len(nodes) == 0 {
+		RenderError(fmt.Sprintf("No GLACIER or DEEP_ARCHIVE objects in %s", node.DisplayString))
+		back()
+		return
+	}
+
+	// Offer the tiers every selected object supports
+
+	tiers := GetRestoreTiers(aws.StringValue(nodes[0].S3Object.StorageClass))
+	for _, n := range nodes {
+		if len(GetRestoreTiers(aws.StringValue(n.S3Object.StorageClass))) < len(tiers) {
+			tiers = GetRestoreTiers(aws.StringValue(n.S3Object.StorageClass))
+		}
+	}
+
+	label := fmt.Sprintf("Restore tier for %d archived objects", len(nodes))
+	RenderChoicePrompt(label, tiers, func(choice int) {
+		tier := tiers[choice]
+		RenderInputPrompt("Days to keep the restored copy", strconv.Itoa(DEFAULT_RESTORE_DAYS), func(input string) {
+			days, err := strconv.ParseInt(input, 10, 64)
+			if err != nil || days < 1 {
+				RenderError(fmt.Sprintf("Invalid number of days: %s", input))
+				back()
+				return
+			}
+			RestoreNodes(bucket, nodes, tier, days, back)
+		}, back)
+	}, back)
+}
+
+func RestoreNodes(bucket BucketWithDisplay, nodes []*Node, tier string, days int64, back func()) {
+
+	// Request restores one at a time with progress, then report
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	var failures []string
+	for i, node := range nodes {
+		key := *node.S3Object.Key
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Requesting restore %d/%d: %s", i+1, len(nodes), key)))
+		err := sess.RestoreArchivedObject(bucket, key, tier, days)
+		if err != nil {
+			log.Println(err)
+			failures = append(failures, fmt.Sprintf("%s: %s", key, err.Error()))
+		}
+	}
+
+	if len(failures) > 0 {
+		lines := append([]string{fmt.Sprintf("%d of %d restore requests failed:", len(failures), len(nodes))}, failures...)
+		RenderTextViewer("Restore Failures", lines, back)
+		return
+	}
+	back()
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Requested %s restore of %d objects for %d days, check progress with <R>", tier, len(nodes), days)))
+}
+
+func RenderRestoreStatus(bucket BucketWithDisplay, node *Node, back func()) {
+
+	// Show the restore state of every archived object at or under a node
+
+	nodes := GetArchivedNodes(node)
+	if len(nodes) == 0 {
+		RenderError(fmt.Sprintf("No GLACIER or DEEP_ARCHIVE objects in %s", node.DisplayString))
+		return
+	}
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+
+	var lines []string
+	restored := 0
+	for i, n := range nodes {
+		key := *n.S3Object.Key
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Checking %d/%d: %s", i+1, len(nodes), key)))
+		status, err := sess.GetRestoreStatus(bucket, key)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%-14s %s: %s", "error", key, err.Error()))
+			continue
+		}
+		if status.Restored {
+			restored += 1
+		}
+		lines = append(lines, fmt.Sprintf("%-14s %s (%s)", status.StorageClass, key, status))
+	}
+
+	title := fmt.Sprintf("Restore Status: %d of %d downloadable", restored, len(nodes))
+	RenderTextViewer(title, lines, back)
+}
@@ -0,0 +1,543 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Azure Blob Storage over its REST API with shared key signing. Containers
+// are buckets and blob names are keys.
+
+type AzureBackend struct {
+	account  string
+	key      []byte
+	endpoint string // the account's blob url, without a trailing "/"
+	region   string
+	client   *http.Client
+}
+
+type azureContainerList struct {
+	Containers []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified"`
+		} `xml:"Properties"`
+	} `xml:"Containers>Container"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+type azureBlobList struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified  string `xml:"Last-Modified"`
+			Etag          string `xml:"Etag"`
+			ContentLength int64  `xml:"Content-Length"`
+			AccessTier    string `xml:"AccessTier"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	Prefixes []struct {
+		Name string `xml:"Name"`
+	} `xml:"Blobs>BlobPrefix"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+type azureError struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func NewAzureBackend(provider ProviderConfig) (backend AzureBackend, err error) {
+	account := os.ExpandEnv(provider.Account)
+	if account == "" {
+		err = errors.New(fmt.Sprintf("Provider %s needs an account", provider.Name))
+		return
+	}
+	key, err := base64.StdEncoding.DecodeString(os.ExpandEnv(provider.SecretKey))
+	if err != nil || len(key) == 0 {
+		err = errors.New(fmt.Sprintf("Provider %s needs secret_key set to the base64 account key", provider.Name))
+		return
+	}
+	endpoint := provider.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf(DEFAULT_AZURE_ENDPOINT, account)
+	}
+	backend = AzureBackend{
+		account:  account,
+		key:      key,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		region:   provider.Region,
+		client:   NewAzureClient(),
+	}
+	if backend.region == "" {
+		backend.region = BACKEND_AZURE
+	}
+	return
+}
+
+func NewAzureClient() *http.Client {
+
+	// Give up on requests Azure doesn't start answering, without cutting
+	// off large transfers that are still streaming
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			DialContext:           (&net.Dialer{Timeout: AZURE_RESPONSE_TIMEOUT}).DialContext,
+			TLSHandshakeTimeout:   AZURE_RESPONSE_TIMEOUT,
+			ResponseHeaderTimeout: AZURE_RESPONSE_TIMEOUT,
+		},
+	}
+}
+
+func (b AzureBackend) Name() string {
+	return BACKEND_AZURE
+}
+
+func (b AzureBackend) getUrl(container string, blob string, query url.Values) (u *url.URL, err error) {
+
+	// Emulators put the account in the path, so append to whatever path
+	// the endpoint has
+
+	u, err = url.Parse(b.endpoint)
+	if err != nil {
+		return
+	}
+	if container != "" {
+		u.Path += "/" + container
+	}
+	if blob != "" {
+		u.Path += "/" + blob
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	u.RawQuery = query.Encode()
+	return
+}
+
+func (b AzureBackend) newRequest(method string, container string, blob string, query url.Values, body io.Reader) (req *http.Request, err error) {
+	u, err := b.getUrl(container, blob, query)
+	if err != nil {
+		return
+	}
+	req, err = http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", AZURE_API_VERSION)
+	return
+}
+
+func (b AzureBackend) sign(req *http.Request) {
+
+	// Shared key authorization: an HMAC of the verb, the standard headers,
+	// the x-ms- headers and the resource
+
+	length := ""
+	if req.ContentLength > 0 {
+		length = fmt.Sprintf("%d", req.ContentLength)
+	}
+	var msHeaders []string
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-ms-") {
+			msHeaders = append(msHeaders, name+":"+strings.Join(values, ","))
+		}
+	}
+	sort.Strings(msHeaders)
+
+	resource := "/" + b.account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	var params []string
+	for name, values := range query {
+		sort.Strings(values)
+		params = append(params, strings.ToLower(name)+":"+strings.Join(values, ","))
+	}
+	sort.Strings(params)
+	for _, param := range params {
+		resource += "\n" + param
+	}
+
+	toSign := strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+		strings.Join(msHeaders, "\n"),
+		resource,
+	}, "\n")
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(toSign))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	req.Header.Set("Authorization", fmt.Sprintf("SharedKey %s:%s", b.account, signature))
+}
+
+func (b AzureBackend) do(req *http.Request) (resp *http.Response, err error) {
+
+	// Send a signed request, turning error responses into aws style errors
+
+	log.Printf("Azure request: %s %s\n", req.Method, req.URL)
+	b.sign(req)
+	resp, err = b.client.Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, ParseAzureError(resp)
+	}
+	return
+}
+
+func ParseAzureError(resp *http.Response) error {
+
+	// Map the codes the rest of the program looks for onto their S3 names
+
+	var parsed azureError
+	data, _ := ioutil.ReadAll(resp.Body)
+	xml.Unmarshal(data, &parsed)
+	code := parsed.Code
+	if code == "" {
+		code = resp.Header.Get("x-ms-error-code")
+	}
+	switch {
+	case code == "BlobNotFound":
+		code = s3.ErrCodeNoSuchKey
+	case code == "ContainerNotFound":
+		code = s3.ErrCodeNoSuchBucket
+	case code == "" && resp.StatusCode == http.StatusNotFound:
+		code = "NotFound"
+	case code == "":
+		code = fmt.Sprintf("HTTP%d", resp.StatusCode)
+	}
+	message := strings.TrimSpace(strings.SplitN(parsed.Message, "\n", 2)[0])
+	if message == "" {
+		message = resp.Status
+	}
+	return awserr.New(code, message, nil)
+}
+
+func ParseAzureTime(value string) *time.Time {
+	parsed, err := time.Parse(time.RFC1123, value)
+	if err != nil {
+		return nil
+	}
+	return aws.Time(parsed)
+}
+
+func QuoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, "\"") {
+		return etag
+	}
+	return fmt.Sprintf("\"%s\"", etag)
+}
+
+func (b AzureBackend) list(container string, query url.Values, result interface{}) (err error) {
+	req, err := b.newRequest("GET", container, "", query, nil)
+	if err != nil {
+		return
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	return xml.NewDecoder(resp.Body).Decode(result)
+}
+
+func (b AzureBackend) ListBuckets() (buckets []*s3.Bucket, err error) {
+	marker := ""
+	for {
+		query := url.Values{"comp": {"list"}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		var page azureContainerList
+		if err = b.list("", query, &page); err != nil {
+			return
+		}
+		for _, container := range page.Containers {
+			buckets = append(buckets, &s3.Bucket{
+				Name:         aws.String(container.Name),
+				CreationDate: ParseAzureTime(container.Properties.LastModified),
+			})
+		}
+		if marker = page.NextMarker; marker == "" {
+			return
+		}
+	}
+}
+
+func (b AzureBackend) GetBucketRegion(bucket string) (string, error) {
+
+	// The account's location isn't part of the blob API
+
+	return b.region, nil
+}
+
+func (b AzureBackend) ListObjects(bucket string, prefix string, delimiter string) (objects []*s3.Object, prefixes []string, err error) {
+	marker := ""
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}}
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		var page azureBlobList
+		if err = b.list(bucket, query, &page); err != nil {
+			return
+		}
+		for _, blob := range page.Blobs {
+			object := &s3.Object{
+				Key:          aws.String(blob.Name),
+				Size:         aws.Int64(blob.Properties.ContentLength),
+				ETag:         aws.String(QuoteETag(blob.Properties.Etag)),
+				LastModified: ParseAzureTime(blob.Properties.LastModified),
+			}
+			if blob.Properties.AccessTier != "" {
+				object.StorageClass = aws.String(blob.Properties.AccessTier)
+			}
+			objects = append(objects, object)
+		}
+		for _, p := range page.Prefixes {
+			prefixes = append(prefixes, p.Name)
+		}
+		if marker = page.NextMarker; marker == "" {
+			return
+		}
+	}
+}
+
+func (b AzureBackend) GetObject(bucket string, key string, versionId string) (body io.ReadCloser, contentType string, err error) {
+	req, err := b.newRequest("GET", bucket, key, url.Values{}, nil)
+	if err != nil {
+		return
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return
+	}
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
+
+func (b AzureBackend) DownloadObject(bucket string, key string, versionId string, dest io.WriterAt) (n int64, err error) {
+	body, _, err := b.GetObject(bucket, key, versionId)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	return CopyToWriterAt(dest, body)
+}
+
+func (b AzureBackend) PutObject(bucket string, key string, body io.Reader, contentType string) (err error) {
+
+	// Stage the body as blocks then commit them, so any size streams
+	// through a fixed buffer
+
+	var ids []string
+	buffer := make([]byte, AZURE_BLOCK_SIZE)
+	for {
+		read, readErr := io.ReadFull(body, buffer)
+		if read > 0 {
+			id := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("block-%08d", len(ids))))
+			query := url.Values{"comp": {"block"}, "blockid": {id}}
+			req, err := b.newRequest("PUT", bucket, key, query, bytes.NewReader(buffer[:read]))
+			if err != nil {
+				return err
+			}
+			resp, err := b.do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			ids = append(ids, id)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	var list bytes.Buffer
+	list.WriteString("<?xml version=\"1.0\" encoding=\"utf-8\"?><BlockList>")
+	for _, id := range ids {
+		fmt.Fprintf(&list, "<Latest>%s</Latest>", id)
+	}
+	list.WriteString("</BlockList>")
+	req, err := b.newRequest("PUT", bucket, key, url.Values{"comp": {"blocklist"}}, bytes.NewReader(list.Bytes()))
+	if err != nil {
+		return
+	}
+	if contentType != "" {
+		req.Header.Set("x-ms-blob-content-type", contentType)
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	return
+}
+
+func (b AzureBackend) CopyObject(srcBucket string, srcKey string, destBucket string, destKey string) (err error) {
+
+	// Copies within an account run on the service and may finish later, so
+	// wait for them like a server side copy in S3
+
+	source, err := b.getUrl(srcBucket, srcKey, url.Values{})
+	if err != nil {
+		return
+	}
+	req, err := b.newRequest("PUT", destBucket, destKey, url.Values{}, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("x-ms-copy-source", source.String())
+	resp, err := b.do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	status := resp.Header.Get("x-ms-copy-status")
+	for status == "pending" {
+		time.Sleep(time.Second)
+		req, err = b.newRequest("HEAD", destBucket, destKey, url.Values{}, nil)
+		if err != nil {
+			return
+		}
+		if resp, err = b.do(req); err != nil {
+			return
+		}
+		resp.Body.Close()
+		status = resp.Header.Get("x-ms-copy-status")
+	}
+	if status != "" && status != "success" {
+		err = errors.New(fmt.Sprintf("Copy of %s %s: %s", srcKey, status, resp.Header.Get("x-ms-copy-status-description")))
+	}
+	return
+}
+
+func (b AzureBackend) DeleteObject(bucket string, key string) (err error) {
+
+	// Missing blobs are not an error, as in S3
+
+	req, err := b.newRequest("DELETE", bucket, key, url.Values{}, nil)
+	if err != nil {
+		return
+	}
+	resp, err := b.do(req)
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return nil
+	}
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+	return
+}
+
+func (b AzureBackend) DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) (errs []*s3.Error, err error) {
+	for _, id := range ids {
+		if deleteErr := b.DeleteObject(bucket, aws.StringValue(id.Key)); deleteErr != nil {
+			errs = append(errs, &s3.Error{
+				Key:       id.Key,
+				VersionId: id.VersionId,
+				Code:      aws.String("InternalError"),
+				Message:   aws.String(deleteErr.Error()),
+			})
+		}
+	}
+	return
+}
+
+func (b AzureBackend) HeadObject(bucket string, key string) (out *s3.HeadObjectOutput, err error) {
+	req, err := b.newRequest("HEAD", bucket, key, url.Values{}, nil)
+	if err != nil {
+		return
+	}
+	resp, err := b.do(req)
+	if err != nil {
+		return
+	}
+	resp.Body.Close()
+
+	// Blank headers stay nil, like missing ones in S3
+
+	header := func(name string) *string {
+		if value := resp.Header.Get(name); value != "" {
+			return aws.String(value)
+		}
+		return nil
+	}
+	out = &s3.HeadObjectOutput{
+		ContentLength:      aws.Int64(resp.ContentLength),
+		ContentType:        header("Content-Type"),
+		ETag:               aws.String(QuoteETag(resp.Header.Get("ETag"))),
+		LastModified:       ParseAzureTime(resp.Header.Get("Last-Modified")),
+		StorageClass:       header("x-ms-access-tier"),
+		CacheControl:       header("Cache-Control"),
+		ContentEncoding:    header("Content-Encoding"),
+		ContentDisposition: header("Content-Disposition"),
+		ContentLanguage:    header("Content-Language"),
+		Metadata:           make(map[string]*string),
+	}
+	for name, values := range resp.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-ms-meta-") && len(values) > 0 {
+			out.Metadata[strings.TrimPrefix(lower, "x-ms-meta-")] = aws.String(values[0])
+		}
+	}
+	return
+}
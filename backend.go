@@ -0,0 +1,210 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// The object operations the explorer needs from a store. Objects and
+// metadata are described with the s3 types so the rest of the program
+// doesn't care which backend it is talking to.
+
+type StorageBackend interface {
+	Name() string
+	ListBuckets() ([]*s3.Bucket, error)
+	GetBucketRegion(bucket string) (string, error)
+	ListObjects(bucket string, prefix string, delimiter string) (objects []*s3.Object, prefixes []string, err error)
+	GetObject(bucket string, key string, versionId string) (body io.ReadCloser, contentType string, err error)
+	DownloadObject(bucket string, key string, versionId string, dest io.WriterAt) (int64, error)
+	PutObject(bucket string, key string, body io.Reader, contentType string) error
+	CopyObject(srcBucket string, srcKey string, destBucket string, destKey string) error
+	DeleteObject(bucket string, key string) error
+	DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) ([]*s3.Error, error)
+	HeadObject(bucket string, key string) (*s3.HeadObjectOutput, error)
+}
+
+// The real thing, one client per region
+
+type AWSBackend struct {
+	S3Service *s3.S3
+}
+
+func (b AWSBackend) Name() string {
+	return BACKEND_AWS
+}
+
+func (b AWSBackend) ListBuckets() (buckets []*s3.Bucket, err error) {
+	resp, err := b.S3Service.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return
+	}
+	buckets = resp.Buckets
+	return
+}
+
+func (b AWSBackend) GetBucketRegion(bucket string) (region string, err error) {
+	region, err = s3manager.GetBucketRegionWithClient(context.Background(), b.S3Service, bucket)
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
+			region = "unknown"
+		} else {
+			log.Printf("Unknown Error: %s\n", err.Error())
+		}
+	}
+	return
+}
+
+func (b AWSBackend) ListObjects(bucket string, prefix string, delimiter string) (objects []*s3.Object, prefixes []string, err error) {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+	if delimiter != "" {
+		input.Delimiter = aws.String(delimiter)
+	}
+	err = b.S3Service.ListObjectsV2Pages(input,
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			objects = append(objects, page.Contents...)
+			for _, p := range page.CommonPrefixes {
+				prefixes = append(prefixes, aws.StringValue(p.Prefix))
+			}
+			return true
+		})
+	return
+}
+
+func (b AWSBackend) GetObject(bucket string, key string, versionId string) (body io.ReadCloser, contentType string, err error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionId != "" {
+		input.VersionId = aws.String(versionId)
+	}
+	out, err := b.S3Service.GetObject(input)
+	if err != nil {
+		return
+	}
+	body = out.Body
+	contentType = aws.StringValue(out.ContentType)
+	return
+}
+
+func (b AWSBackend) DownloadObject(bucket string, key string, versionId string, dest io.WriterAt) (int64, error) {
+
+	// Download in parallel parts
+
+	downloader := s3manager.NewDownloaderWithClient(b.S3Service, func(d *s3manager.Downloader) {
+		d.PartSize = 64 * 1024 * 1024 // 64MB per part
+	})
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if versionId != "" {
+		input.VersionId = aws.String(versionId)
+	}
+	return downloader.Download(dest, input)
+}
+
+func (b AWSBackend) PutObject(bucket string, key string, body io.Reader, contentType string) (err error) {
+	uploader := s3manager.NewUploaderWithClient(b.S3Service, func(u *s3manager.Uploader) {
+		u.PartSize = 64 * 1024 * 1024 // 64MB per part
+	})
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   body,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	_, err = uploader.Upload(input)
+	return
+}
+
+func (b AWSBackend) CopyObject(srcBucket string, srcKey string, destBucket string, destKey string) (err error) {
+	_, err = b.S3Service.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(destBucket),
+		Key:        aws.String(destKey),
+		CopySource: aws.String(CopySourcePath(srcBucket, srcKey, "")),
+	})
+	return
+}
+
+func (b AWSBackend) DeleteObject(bucket string, key string) (err error) {
+	_, err = b.S3Service.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return
+}
+
+func (b AWSBackend) DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) (errs []*s3.Error, err error) {
+	out, err := b.S3Service.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3.Delete{
+			Objects: ids,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return
+	}
+	errs = out.Errors
+	return
+}
+
+func (b AWSBackend) HeadObject(bucket string, key string) (*s3.HeadObjectOutput, error) {
+	return b.S3Service.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+}
+
+func CopyToWriterAt(dest io.WriterAt, body io.Reader) (n int64, err error) {
+
+	// Downloads for backends without ranged parallel gets
+
+	buffer := make([]byte, 1024*1024)
+	for {
+		read, readErr := body.Read(buffer)
+		if read > 0 {
+			if _, err = dest.WriteAt(buffer[:read], n); err != nil {
+				return
+			}
+			n += int64(read)
+		}
+		if readErr == io.EOF {
+			return
+		}
+		if readErr != nil {
+			return n, readErr
+		}
+	}
+}
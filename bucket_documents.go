@@ -0,0 +1,434 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// A bucket configuration that can be edited as a json document
+
+type BucketDocument struct {
+	Name     string
+	Template string
+	Fetch    func(s S3Session, bucket *string) (string, error)
+	Validate func(doc []byte) []string
+	Apply    func(s S3Session, bucket *string, doc []byte) error
+	Delete   func(s S3Session, bucket *string) error
+}
+
+func GetBucketDocuments() []BucketDocument {
+
+	// The editable configurations, keyed by the property they edit
+
+	return []BucketDocument{
+		{
+			Name:     BUCKET_PROPERTY_POLICY,
+			Template: "{\n  \"Version\": \"2012-10-17\",\n  \"Statement\": []\n}\n",
+			Fetch: func(s S3Session, bucket *string) (doc string, err error) {
+				out, err := s.S3Service.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: bucket})
+				if err != nil {
+					return
+				}
+				return IndentDocument([]byte(aws.StringValue(out.Policy)))
+			},
+			Validate: ValidatePolicyDocument,
+			Apply: func(s S3Session, bucket *string, doc []byte) (err error) {
+				_, err = s.S3Service.PutBucketPolicy(&s3.PutBucketPolicyInput{
+					Bucket: bucket,
+					Policy: aws.String(string(doc)),
+				})
+				return
+			},
+			Delete: func(s S3Session, bucket *string) (err error) {
+				_, err = s.S3Service.DeleteBucketPolicy(&s3.DeleteBucketPolicyInput{Bucket: bucket})
+				return
+			},
+		},
+		{
+			Name:     BUCKET_PROPERTY_CORS,
+			Template: "{\n  \"CORSRules\": []\n}\n",
+			Fetch: func(s S3Session, bucket *string) (doc string, err error) {
+				out, err := s.S3Service.GetBucketCors(&s3.GetBucketCorsInput{Bucket: bucket})
+				if err != nil {
+					return
+				}
+				return MarshalDocument(&s3.CORSConfiguration{CORSRules: out.CORSRules})
+			},
+			Validate: ValidateCorsDocument,
+			Apply: func(s S3Session, bucket *string, doc []byte) (err error) {
+				var cors s3.CORSConfiguration
+				err = json.Unmarshal(doc, &cors)
+				if err != nil {
+					return
+				}
+				_, err = s.S3Service.PutBucketCors(&s3.PutBucketCorsInput{
+					Bucket:            bucket,
+					CORSConfiguration: &cors,
+				})
+				return
+			},
+			Delete: func(s S3Session, bucket *string) (err error) {
+				_, err = s.S3Service.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: bucket})
+				return
+			},
+		},
+		{
+			Name:     BUCKET_PROPERTY_LIFECYCLE,
+			Template: "{\n  \"Rules\": []\n}\n",
+			Fetch: func(s S3Session, bucket *string) (doc string, err error) {
+				out, err := s.S3Service.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+				if err != nil {
+					return
+				}
+				return MarshalDocument(&s3.BucketLifecycleConfiguration{Rules: out.Rules})
+			},
+			Validate: ValidateLifecycleDocument,
+			Apply: func(s S3Session, bucket *string, doc []byte) (err error) {
+				var lifecycle s3.BucketLifecycleConfiguration
+				err = json.Unmarshal(doc, &lifecycle)
+				if err != nil {
+					return
+				}
+				_, err = s.S3Service.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+					Bucket:                 bucket,
+					LifecycleConfiguration: &lifecycle,
+				})
+				return
+			},
+			Delete: func(s S3Session, bucket *string) (err error) {
+				_, err = s.S3Service.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{Bucket: bucket})
+				return
+			},
+		},
+	}
+}
+
+func GetBucketDocument(name string) (document BucketDocument, ok bool) {
+	for _, d := range GetBucketDocuments() {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return
+}
+
+func (s S3Session) GetLiveDocument(bucket BucketWithDisplay, document BucketDocument) (doc string, err error) {
+
+	// Fetch the live document, empty if it was never configured
+
+	log.Printf("Fetching live %s for %s\n", document.Name, *bucket.bucket.Name)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	doc, err = document.Fetch(s, bucket.bucket.Name)
+	if IsNotConfiguredError(err) {
+		return "", nil
+	}
+	return
+}
+
+func IndentDocument(data []byte) (doc string, err error) {
+
+	// Re-indent a json document consistently
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, data, "", "  ")
+	if err != nil {
+		return
+	}
+	doc = indented.String() + "\n"
+	return
+}
+
+func StripNulls(value interface{}) interface{} {
+
+	// Remove null members so sdk structs don't show every unset field
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			if member == nil {
+				delete(v, key)
+			} else {
+				v[key] = StripNulls(member)
+			}
+		}
+	case []interface{}:
+		for i, member := range v {
+			v[i] = StripNulls(member)
+		}
+	}
+	return value
+}
+
+func MarshalDocument(value interface{}) (doc string, err error) {
+
+	// Marshal an sdk struct into an editable document
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	var generic interface{}
+	err = json.Unmarshal(data, &generic)
+	if err != nil {
+		return
+	}
+	data, err = json.Marshal(StripNulls(generic))
+	if err != nil {
+		return
+	}
+	return IndentDocument(data)
+}
+
+func DescribeJSONError(doc []byte, err error) string {
+
+	// Point syntax errors at a line number
+
+	if serr, ok := err.(*json.SyntaxError); ok {
+		line := bytes.Count(doc[:serr.Offset], []byte("\n")) + 1
+		return fmt.Sprintf("line %d: %s", line, serr.Error())
+	}
+	return err.Error()
+}
+
+func ParseDocumentObject(doc []byte) (object map[string]interface{}, problems []string) {
+
+	// Every document must be a single json object
+
+	err := json.Unmarshal(doc, &object)
+	if err != nil {
+		problems = append(problems, DescribeJSONError(doc, err))
+	}
+	return
+}
+
+func DecodeDocumentStrict(doc []byte, value interface{}) (problems []string) {
+
+	// Decode into an sdk struct, rejecting misspelled fields
+
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(value)
+	if err != nil {
+		problems = append(problems, DescribeJSONError(doc, err))
+	}
+	return
+}
+
+func GetObjectList(value interface{}) []map[string]interface{} {
+
+	// Treat a single object or an array of objects the same way
+
+	var objects []map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		objects = append(objects, v)
+	case []interface{}:
+		for _, member := range v {
+			if object, ok := member.(map[string]interface{}); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+	return objects
+}
+
+func ValidatePolicyDocument(doc []byte) (problems []string) {
+
+	// Basic IAM policy grammar checks for a bucket policy
+
+	object, problems := ParseDocumentObject(doc)
+	if len(problems) > 0 {
+		return
+	}
+	if version, ok := object["Version"]; ok && version != "2012-10-17" && version != "2008-10-17" {
+		problems = append(problems, fmt.Sprintf("Version must be 2012-10-17 or 2008-10-17, not %v", version))
+	}
+	statements := GetObjectList(object["Statement"])
+	if len(statements) == 0 {
+		problems = append(problems, "Statement must contain at least one statement")
+	}
+	for i, statement := range statements {
+		where := fmt.Sprintf("Statement %d", i)
+		if sid, ok := statement["Sid"].(string); ok {
+			where = fmt.Sprintf("Statement %q", sid)
+		}
+		if effect := statement["Effect"]; effect != "Allow" && effect != "Deny" {
+			problems = append(problems, fmt.Sprintf("%s: Effect must be Allow or Deny", where))
+		}
+		if statement["Action"] == nil && statement["NotAction"] == nil {
+			problems = append(problems, fmt.Sprintf("%s: needs Action or NotAction", where))
+		}
+		if statement["Resource"] == nil && statement["NotResource"] == nil {
+			problems = append(problems, fmt.Sprintf("%s: needs Resource or NotResource", where))
+		}
+		if statement["Principal"] == nil && statement["NotPrincipal"] == nil {
+			problems = append(problems, fmt.Sprintf("%s: bucket policies need Principal or NotPrincipal", where))
+		}
+	}
+	return
+}
+
+func ValidateCorsDocument(doc []byte) (problems []string) {
+
+	// CORS rules need origins and known methods
+
+	var cors s3.CORSConfiguration
+	problems = DecodeDocumentStrict(doc, &cors)
+	if len(problems) > 0 {
+		return
+	}
+	if len(cors.CORSRules) == 0 {
+		problems = append(problems, "CORSRules must contain at least one rule (empty the file to remove CORS)")
+	}
+	methods := map[string]bool{"GET": true, "PUT": true, "POST": true, "DELETE": true, "HEAD": true}
+	for i, rule := range cors.CORSRules {
+		if len(rule.AllowedOrigins) == 0 {
+			problems = append(problems, fmt.Sprintf("Rule %d: AllowedOrigins is required", i))
+		}
+		if len(rule.AllowedMethods) == 0 {
+			problems = append(problems, fmt.Sprintf("Rule %d: AllowedMethods is required", i))
+		}
+		for _, method := range rule.AllowedMethods {
+			if !methods[aws.StringValue(method)] {
+				problems = append(problems, fmt.Sprintf("Rule %d: unknown method %s", i, aws.StringValue(method)))
+			}
+		}
+	}
+	return
+}
+
+func ValidateLifecycleDocument(doc []byte) (problems []string) {
+
+	// Lifecycle rules need a status, a scope and at least one action
+
+	var lifecycle s3.BucketLifecycleConfiguration
+	problems = DecodeDocumentStrict(doc, &lifecycle)
+	if len(problems) > 0 {
+		return
+	}
+	object, _ := ParseDocumentObject(doc)
+	rules := GetObjectList(object["Rules"])
+	if len(rules) == 0 {
+		problems = append(problems, "Rules must contain at least one rule (empty the file to remove lifecycle rules)")
+	}
+	actions := []string{
+		"Expiration",
+		"Transitions",
+		"NoncurrentVersionExpiration",
+		"NoncurrentVersionTransitions",
+		"AbortIncompleteMultipartUpload",
+	}
+	for i, rule := range rules {
+		where := fmt.Sprintf("Rule %d", i)
+		if id, ok := rule["ID"].(string); ok {
+			where = fmt.Sprintf("Rule %q", id)
+		}
+		if status := rule["Status"]; status != "Enabled" && status != "Disabled" {
+			problems = append(problems, fmt.Sprintf("%s: Status must be Enabled or Disabled", where))
+		}
+		if rule["Filter"] == nil && rule["Prefix"] == nil {
+			problems = append(problems, fmt.Sprintf("%s: needs a Filter (use {} for the whole bucket)", where))
+		}
+		hasAction := false
+		for _, action := range actions {
+			if rule[action] != nil {
+				hasAction = true
+			}
+		}
+		if !hasAction {
+			problems = append(problems, fmt.Sprintf("%s: needs at least one of %s", where, strings.Join(actions, ", ")))
+		}
+	}
+	return
+}
+
+func DiffLines(old []string, new []string) (diff []string) {
+
+	// A minimal line diff from the longest common subsequence
+
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			diff = append(diff, "  "+old[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+old[i])
+			i++
+		default:
+			diff = append(diff, "+ "+new[j])
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		diff = append(diff, "- "+old[i])
+	}
+	for ; j < len(new); j++ {
+		diff = append(diff, "+ "+new[j])
+	}
+	return
+}
+
+func BackupDocument(bucket BucketWithDisplay, document BucketDocument, doc string) (path string, err error) {
+
+	// Save the live document before replacing it
+
+	err = os.MkdirAll(config.BackupDir, DEFAULT_DIRECTORY_MODE)
+	if err != nil {
+		return
+	}
+	name := fmt.Sprintf("%s-%s-%s.json",
+		*bucket.bucket.Name,
+		strings.ToLower(strings.Replace(document.Name, " ", "-", -1)),
+		time.Now().Format("20060102T150405"),
+	)
+	path = filepath.Join(config.BackupDir, name)
+	log.Printf("Backing up %s to %s\n", document.Name, path)
+	err = ioutil.WriteFile(path, []byte(doc), DEFAULT_FILE_MODE)
+	return
+}
@@ -0,0 +1,45 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		old  []string
+		new  []string
+		diff []string
+	}{
+		{"same", []string{"a", "b"}, []string{"a", "b"}, []string{"  a", "  b"}},
+		{"added", []string{"a"}, []string{"a", "b"}, []string{"  a", "+ b"}},
+		{"removed", []string{"a", "b"}, []string{"b"}, []string{"- a", "  b"}},
+		{"changed", []string{"a", "b", "c"}, []string{"a", "x", "c"}, []string{"  a", "- b", "+ x", "  c"}},
+		{"from nothing", nil, []string{"a"}, []string{"+ a"}},
+		{"to nothing", []string{"a"}, nil, []string{"- a"}},
+		{"both empty", nil, nil, nil},
+	}
+	for _, test := range tests {
+		if diff := DiffLines(test.old, test.new); !reflect.DeepEqual(diff, test.diff) {
+			t.Errorf("%s: got %q, want %q", test.name, diff, test.diff)
+		}
+	}
+}
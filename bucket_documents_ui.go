@@ -0,0 +1,179 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gizak/termui"
+)
+
+func EditText(text string, name string) (edited string, err error) {
+
+	// Round trip some text through the user's editor using a temp file
+
+	tempDir, err := ioutil.TempDir("", "s3explorer")
+	if err != nil {
+		return
+	}
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, name)
+	err = ioutil.WriteFile(path, []byte(text), DEFAULT_FILE_MODE)
+	if err != nil {
+		return
+	}
+
+	SuspendUi(func() {
+		err = RunEditor(path)
+	})
+	if err != nil {
+		return
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	edited = string(data)
+	return
+}
+
+func RenderEditBucketDocument(bucket BucketWithDisplay, sess S3Session, document BucketDocument, back func()) {
+
+	// Fetch the live document and start editing it (or a template)
+
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Fetching %s", document.Name)))
+	live, err := sess.GetLiveDocument(bucket, document)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	text := live
+	if text == "" {
+		text = document.Template
+	}
+	EditBucketDocument(bucket, sess, document, live, text, back)
+}
+
+func EditBucketDocument(bucket BucketWithDisplay, sess S3Session, document BucketDocument, live string, text string, back func()) {
+
+	// Edit, validate, diff and then offer to apply
+
+	name := fmt.Sprintf("%s-%s.json", *bucket.bucket.Name, strings.ToLower(strings.Replace(document.Name, " ", "-", -1)))
+	edited, err := EditText(text, name)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	editAgain := func() {
+		EditBucketDocument(bucket, sess, document, live, edited, back)
+	}
+
+	// An empty file removes the configuration
+
+	if strings.TrimSpace(edited) == "" {
+		if live == "" {
+			back()
+			termui.Render(CreateStatusPrompt("No changes"))
+			return
+		}
+		msg := fmt.Sprintf("Remove the %s from %s?", document.Name, *bucket.bucket.Name)
+		RenderConfirmPrompt("Remove Configuration", msg, func() {
+			ApplyBucketDocument(bucket, sess, document, live, "", back)
+		}, back)
+		return
+	}
+
+	if edited == live || (live == "" && edited == document.Template) {
+		back()
+		termui.Render(CreateStatusPrompt("No changes"))
+		return
+	}
+
+	// Validate locally before showing the diff
+
+	problems := document.Validate([]byte(edited))
+	if len(problems) > 0 {
+		lines := append([]string{fmt.Sprintf("The %s is not valid:", document.Name), ""}, problems...)
+		RenderTextViewer("Validation Failed", lines, back, "<e> edit again")
+		termui.Handle("/sys/kbd/e", func(termui.Event) {
+			editAgain()
+		})
+		return
+	}
+
+	diff := DiffLines(strings.Split(strings.TrimRight(live, "\n"), "\n"), strings.Split(strings.TrimRight(edited, "\n"), "\n"))
+	title := fmt.Sprintf("Changes to %s: %s", document.Name, *bucket.bucket.Name)
+	RenderTextViewer(title, diff, back, "<y> apply", "<e> edit again")
+	termui.Handle("/sys/kbd/e", func(termui.Event) {
+		editAgain()
+	})
+	termui.Handle("/sys/kbd/y", func(termui.Event) {
+		ApplyBucketDocument(bucket, sess, document, live, edited, back)
+	})
+}
+
+func ApplyBucketDocument(bucket BucketWithDisplay, sess S3Session, document BucketDocument, live string, edited string, back func()) {
+
+	// Back up the live document, then replace (or remove) it
+
+	backup := ""
+	if live != "" {
+		var err error
+		backup, err = BackupDocument(bucket, document, live)
+		if err != nil {
+			log.Println(err)
+			RenderError(fmt.Sprintf("Not applying, backup failed: %s", err.Error()))
+			back()
+			return
+		}
+	}
+
+	var err error
+	if edited == "" {
+		log.Printf("Removing %s from %s\n", document.Name, *bucket.bucket.Name)
+		err = document.Delete(sess, bucket.bucket.Name)
+	} else {
+		log.Printf("Applying %s to %s\n", document.Name, *bucket.bucket.Name)
+		err = document.Apply(sess, bucket.bucket.Name, []byte(edited))
+	}
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	back()
+	msg := fmt.Sprintf("Applied %s", document.Name)
+	if backup != "" {
+		msg = fmt.Sprintf("%s, previous version saved to %s", msg, backup)
+	}
+	termui.Render(CreateStatusPrompt(msg))
+}
@@ -18,25 +18,131 @@ along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
+	"fmt"
 	"log"
-	"os"
 	"path"
 	"path/filepath"
 
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gizak/termui"
 )
 
-func RenderBucketExplorerListing(bucket BucketWithDisplay, nodes []*Node, selection int, deferFunc func()) {
+type BucketExplorer struct {
+	bucket      BucketWithDisplay
+	tree        *Node
+	deferFunc   func()
+	showDeleted bool
+	marked      map[*Node]bool
+}
+
+func RenderBucketExplorerListing(explorer *BucketExplorer, dir *Node, selection int) {
+
+	bucket := explorer.bucket
+	title := GetExplorerTitle(explorer)
 
 	// Get a UI ready list depending on where the selection pointer is
 
-	list := CreateDirectoryList(bucket.displayString, nodes, selection)
+	nodes := GetNodeDirectory(dir)
+	list := CreateDirectoryList(title, nodes, selection, explorer.marked)
 	termui.Clear()
-	termui.Render(list, RenderHelp())
+	termui.Render(list, RenderExplorerHelp())
 
 	// Set default handlers and defer tempdir removal
 
-	SetDefaultHandlers(deferFunc)
+	termui.ResetHandlers()
+	SetDefaultHandlers(explorer.deferFunc)
+
+	// Dialogs return here with the same selection
+
+	back := func() {
+		RenderBucketExplorerListing(explorer, dir, selection)
+	}
+
+	// ? lists all the keys
+
+	termui.Handle("/sys/kbd/?", func(termui.Event) {
+		RenderTextViewer("Explorer Keys", GetExplorerKeyLines(), back)
+	})
+
+	// P presigns an upload into the current directory
+
+	termui.Handle("/sys/kbd/P", func(termui.Event) {
+		RenderShareUpload(bucket, dir, back)
+	})
+
+	// Back goes up a directory, or to the buckets from the root
+
+	SetBackHandler(func() {
+		if dir.Parent == nil {
+			log.Println("Reached directory root, returning to buckets")
+			explorer.deferFunc()
+			ReloadMainBuckets()
+		} else {
+			log.Printf("Going back to directory: %+v\n", dir.Parent.DisplayString)
+			RenderBucketExplorerListing(explorer, dir.Parent, 0)
+		}
+	})
+
+	// D shows what is using the storage in this directory
+
+	termui.Handle("/sys/kbd/D", func(termui.Event) {
+		RenderDiskUsage(explorer, dir, back)
+	})
+
+	// $ estimates the monthly storage cost of this directory
+
+	termui.Handle("/sys/kbd/$", func(termui.Event) {
+		RenderDirectoryCost(explorer, dir, back)
+	})
+
+	// t opens the largest, oldest and newest object reports
+
+	termui.Handle("/sys/kbd/t", func(termui.Event) {
+		RenderReports(explorer, dir, back)
+	})
+
+	// F finds duplicate objects under this directory
+
+	termui.Handle("/sys/kbd/F", func(termui.Event) {
+		RenderDuplicates(explorer, dir, back)
+	})
+
+	// L compares this directory with a local one
+
+	termui.Handle("/sys/kbd/L", func(termui.Event) {
+		RenderLocalDiff(explorer, dir, back)
+	})
+
+	// S syncs this directory with a local one
+
+	termui.Handle("/sys/kbd/S", func(termui.Event) {
+		RenderSync(explorer, dir, back)
+	})
+
+	// C compares this directory with another prefix
+
+	termui.Handle("/sys/kbd/C", func(termui.Event) {
+		RenderCompare(explorer, dir, back)
+	})
+
+	// l browses local files, uploads go into this directory
+
+	termui.Handle("/sys/kbd/l", func(termui.Event) {
+		RenderLocalBrowser(explorer, dir, back)
+	})
+
+	// d toggles showing deleted objects
+
+	termui.Handle("/sys/kbd/d", func(termui.Event) {
+		explorer.deferFunc()
+		LoadBucketExplorer(explorer.bucket, !explorer.showDeleted)
+	})
+
+	// Nothing else to do in an empty directory
+
+	if len(nodes) == 0 {
+		return
+	}
 
 	// Up key moves up
 
@@ -45,8 +151,8 @@ func RenderBucketExplorerListing(bucket BucketWithDisplay, nodes []*Node, select
 			return
 		} else {
 			selection -= 1
-			list := CreateDirectoryList(bucket.displayString, nodes, selection)
-			termui.Render(list, RenderHelp())
+			list := CreateDirectoryList(title, nodes, selection, explorer.marked)
+			termui.Render(list, RenderExplorerHelp())
 		}
 	})
 
@@ -57,8 +163,8 @@ func RenderBucketExplorerListing(bucket BucketWithDisplay, nodes []*Node, select
 			return
 		} else {
 			selection += 1
-			list := CreateDirectoryList(bucket.displayString, nodes, selection)
-			termui.Render(list, RenderHelp())
+			list := CreateDirectoryList(title, nodes, selection, explorer.marked)
+			termui.Render(list, RenderExplorerHelp())
 		}
 	})
 
@@ -68,44 +174,40 @@ func RenderBucketExplorerListing(bucket BucketWithDisplay, nodes []*Node, select
 
 		if nodes[selection].Info.IsDir {
 
-			// A directory was selected
+			// A directory was selected, ".." is the real parent
 
 			log.Printf("Selected Directory: %s\n", nodes[selection].DisplayString)
+			target := nodes[selection]
+			if target.DisplayString == ".." {
+				target = dir.Parent
+			}
 
-			SetBackHandler(func() {
-				termui.ResetHandlers()
-				if nodes[0].Parent == nil {
-					log.Println("Reached directory root, returning to buckets")
-					deferFunc()
-					ReloadMainBuckets()
-				} else {
-					log.Printf("Going back to directory: %+v\n", nodes[0].Parent.DisplayString)
-					listing := GetNodeDirectory(nodes[0].Parent)
-					RenderBucketExplorerListing(bucket, listing, 0, deferFunc)
-				}
-			})
-
-			// Call parent function for selected node
-
-			log.Printf("Descending into node: %+v\n", nodes[selection].DisplayString)
-			listing := GetNodeDirectory(nodes[selection])
-			RenderBucketExplorerListing(bucket, listing, 0, deferFunc)
+			log.Printf("Descending into node: %+v\n", target.DisplayString)
+			RenderBucketExplorerListing(explorer, target, 0)
 
 		} else {
 
 			// A File was selected
 
 			log.Printf("File Selected: %s\n", nodes[selection].DisplayString)
+			if nodes[selection].IsDeleted {
+				RenderError("This object is deleted, undelete it first")
+				return
+			}
+			if !CheckArchivedNode(bucket, nodes[selection], back) {
+				return
+			}
 
-			// Current downloads default to working directory
+			// Downloads go to the download target (the working directory
+			// unless changed in the local browser)
 
-			dest := filepath.Join(currentWorkingDir, path.Base(*nodes[selection].S3Object.Key))
+			dest := filepath.Join(downloadDir, path.Base(*nodes[selection].S3Object.Key))
 			p := CreateDownloadPrompt(dest)
 			termui.Render(p)
 
 			// Create an AWS Session in the region of the bucket
 
-			sess, err := InitSession(bucket.region)
+			sess, err := InitBucketSession(bucket)
 			if err != nil {
 				log.Println(err)
 				RenderError(err.Error())
@@ -126,66 +228,314 @@ func RenderBucketExplorerListing(bucket BucketWithDisplay, nodes []*Node, select
 
 	})
 
+	// o opens a file with an external program
+
+	termui.Handle("/sys/kbd/o", func(termui.Event) {
+		if nodes[selection].Info.IsDir {
+			return
+		}
+		if nodes[selection].IsDeleted {
+			RenderError("This object is deleted, undelete it first")
+			return
+		}
+		if !CheckArchivedNode(bucket, nodes[selection], back) {
+			return
+		}
+		status := OpenObjectExternally(bucket, *nodes[selection].S3Object.Key, "")
+		back()
+		if status != "" {
+			termui.Render(CreateStatusPrompt(status))
+		}
+	})
+
+	// p presigns a download of a file
+
+	termui.Handle("/sys/kbd/p", func(termui.Event) {
+		if nodes[selection].Info.IsDir {
+			return
+		}
+		if nodes[selection].IsDeleted {
+			RenderError("This object is deleted, undelete it first")
+			return
+		}
+		if !CheckArchivedNode(bucket, nodes[selection], back) {
+			return
+		}
+		RenderShareObject(bucket, nodes[selection], back)
+	})
+
+	// v shows the version history of a file
+
+	termui.Handle("/sys/kbd/v", func(termui.Event) {
+		if nodes[selection].Info.IsDir {
+			return
+		}
+		RenderObjectVersions(bucket, nodes[selection], back)
+	})
+
+	// r restores archived objects at or under the selection
+
+	termui.Handle("/sys/kbd/r", func(termui.Event) {
+		target := nodes[selection]
+		if target.DisplayString == ".." {
+			return
+		}
+		RenderRestorePrompt(bucket, target, back)
+	})
+
+	// R shows the restore status of archived objects at or under the selection
+
+	termui.Handle("/sys/kbd/R", func(termui.Event) {
+		target := nodes[selection]
+		if target.DisplayString == ".." {
+			return
+		}
+		RenderRestoreStatus(bucket, target, back)
+	})
+
+	// space marks or unmarks the selection for bulk actions
+
+	termui.Handle("/sys/kbd/<space>", func(termui.Event) {
+		target := nodes[selection]
+		if target.DisplayString == ".." {
+			return
+		}
+		if explorer.marked[target] {
+			delete(explorer.marked, target)
+		} else {
+			explorer.marked[target] = true
+		}
+		if selection < len(nodes)-1 {
+			selection += 1
+		}
+		back()
+	})
+
+	// M clears all marks
+
+	termui.Handle("/sys/kbd/M", func(termui.Event) {
+		explorer.marked = make(map[*Node]bool)
+		back()
+	})
+
+	// s changes the storage class of the marked set or the selection
+
+	termui.Handle("/sys/kbd/s", func(termui.Event) {
+		targets := GetExplorerTargets(explorer, nodes[selection])
+		if len(targets) == 0 {
+			return
+		}
+		RenderChangeStorageClass(explorer, targets, back)
+	})
+
+	// u undeletes a file or everything deleted under a directory
+
+	termui.Handle("/sys/kbd/u", func(termui.Event) {
+		if !explorer.showDeleted {
+			RenderError("Toggle showing deleted objects with <d> first")
+			return
+		}
+		target := nodes[selection]
+		if target.DisplayString == ".." {
+			return
+		}
+		RenderUndelete(explorer, target, back)
+	})
+
 }
 
-func RenderBucketExplorer(bucket BucketWithDisplay) {
+func JumpToNode(explorer *BucketExplorer, node *Node) {
 
-	// init selection pointer
+	// Show a node's directory with the node selected
 
-	var selection int
-	selection = 0
+	dir := node.Parent
+	selection := 0
+	for i, entry := range GetNodeDirectory(dir) {
+		if entry == node {
+			selection = i
+		}
+	}
+	log.Printf("Jumping to node: %s\n", node.FullPath)
+	RenderBucketExplorerListing(explorer, dir, selection)
+}
 
-	// get AWS session in region of bucket
+func GetExplorerTargets(explorer *BucketExplorer, selected *Node) (targets []*Node) {
+
+	// Bulk actions work on the marked set if there is one, otherwise the
+	// selection (but never "..")
+
+	if len(explorer.marked) > 0 {
+		for node := range explorer.marked {
+			targets = append(targets, node)
+		}
+		return
+	}
+	if selected.DisplayString != ".." {
+		targets = append(targets, selected)
+	}
+	return
+}
+
+func GetExplorerTitle(explorer *BucketExplorer) string {
+
+	// The list title shows the bucket and any active modes
+
+	title := explorer.bucket.displayString
+	if explorer.showDeleted {
+		title = fmt.Sprintf("%s [showing deleted]", title)
+	}
+	if len(explorer.marked) > 0 {
+		title = fmt.Sprintf("%s [%d marked]", title, len(explorer.marked))
+	}
+	return title
+}
+
+var explorerKeys = [][2]string{
+	{"<up>/<down>", "Move the selection"},
+	{"<enter>", "Open a directory or download a file"},
+	{"<b>", "Go back"},
+	{"<q>", "Quit"},
+	{"<o>", "Open a file with an external program"},
+	{"<p>", "Presign a download URL for a file"},
+	{"<P>", "Presign an upload URL into this directory"},
+	{"<v>", "Browse the version history of a file"},
+	{"<d>", "Toggle showing deleted objects (versioned buckets)"},
+	{"<u>", "Undelete a file or everything deleted under a directory"},
+	{"<r>", "Restore GLACIER/DEEP_ARCHIVE objects in a file or directory"},
+	{"<R>", "Show restore status of archived objects in a file or directory"},
+	{"<space>", "Mark or unmark a file or directory for bulk actions"},
+	{"<M>", "Clear all marks"},
+	{"<s>", "Change the storage class of the marked set or selection"},
+	{"<D>", "Show disk usage of this directory, largest first"},
+	{"<$>", "Estimate the monthly storage cost of this directory"},
+	{"<t>", "Largest, oldest and newest objects and an age histogram"},
+	{"<F>", "Find duplicate objects under this directory"},
+	{"<L>", "Compare this directory with a local directory"},
+	{"<S>", "Sync this directory with a local directory"},
+	{"<C>", "Compare this directory with another prefix or bucket"},
+	{"<l>", "Browse local files, upload into this directory, pick the download target"},
+}
+
+func GetExplorerKeyLines() (lines []string) {
 
-	sess, err := InitSession(bucket.region)
+	// Format the explorer keys for display
+
+	for _, key := range explorerKeys {
+		lines = append(lines, fmt.Sprintf("%-14s %s", key[0], key[1]))
+	}
+	return
+}
+
+func RenderExplorerHelp() *termui.Par {
+
+	// Help window for the bucket explorer
+
+	return RenderHelp("<?> more keys")
+}
+
+func OpenObjectExternally(bucket BucketWithDisplay, key string, versionId string) (status string) {
+
+	// Stream an object into the configured program for its type and
+	// return a message with its exit status
+
+	log.Printf("Opening externally: %s\n", key)
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Opening %s", path.Base(key))))
+
+	sess, err := InitBucketSession(bucket)
 	if err != nil {
 		log.Println(err)
-		os.Exit(EXIT_FAILED_AWS_CONNECT)
+		RenderError(err.Error())
+		return
 	}
 
-	// retrieve all objects for bucket
-
-	objects, err := sess.GetBucketObjects(bucket)
+	body, contentType, err := sess.GetObjectVersionReader(bucket, key, versionId)
 	if err != nil {
-		ReloadMainBucketsWithError(err)
+		log.Println(err)
+		RenderError(err.Error())
 		return
+	}
+	defer body.Close()
 
+	opener, ok := MatchOpener(config.Openers, key, contentType)
+	if !ok {
+		RenderError(fmt.Sprintf("No opener configured for %s (%s)", path.Base(key), contentType))
+		return
 	}
 
-	// create a local mock filesystem for easier indexing
+	// Give the terminal to the program while it runs
 
-	mockFsRoot, err := CreateMockFs(objects)
+	var exitStatus int
+	SuspendUi(func() {
+		exitStatus, err = RunOpener(opener, key, contentType, body)
+	})
 	if err != nil {
-		ReloadMainBucketsWithError(err)
+		log.Println(err)
+		RenderError(err.Error())
+		return
 	}
+	status = fmt.Sprintf("%s exited with status %d", opener.Command, exitStatus)
+	return
+}
 
-	// Set clean function to pass to all default handlers
+func RenderBucketExplorer(bucket BucketWithDisplay) {
 
-	deferFunc := func() {
-		log.Printf("Cleaning Temp Directory: %s\n", mockFsRoot)
-		err := os.RemoveAll(mockFsRoot)
-		if err != nil {
-			log.Printf("Error cleaning Temp (%s): %s\n", mockFsRoot, err)
-		}
+	// Open a bucket showing only live objects
+
+	LoadBucketExplorer(bucket, false)
+}
+
+func LoadBucketExplorer(bucket BucketWithDisplay, showDeleted bool) {
+
+	// init selection pointer
+
+	var selection int
+	selection = 0
+
+	// get AWS session in region of bucket
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		ReloadMainBucketsWithError(err)
+		return
 	}
 
-	// Evaluate the directory tree
+	// retrieve all objects for bucket, including deleted ones if asked
 
-	tree, err := NewTree(objects, mockFsRoot)
+	var objects []*s3.Object
+	var deleted map[string]bool
+	if showDeleted {
+		termui.Render(CreateStatusPrompt("Listing all object versions"))
+		objects, deleted, err = sess.GetBucketObjectsWithDeleted(bucket)
+	} else {
+		objects, err = sess.GetBucketObjects(bucket)
+	}
 	if err != nil {
 		ReloadMainBucketsWithError(err)
+		return
+
 	}
 
-	// Get a listing for the root node
+	// Index the objects as a directory tree
 
-	listing := GetNodeDirectory(tree)
+	tree, deferFunc, err := BuildBucketTree(objects)
 	if err != nil {
 		ReloadMainBucketsWithError(err)
+		return
+	}
+	if showDeleted {
+		MarkDeletedNodes(tree, deleted)
 	}
 
-	// Render the bucket explorer
+	// Render the bucket explorer at the root node
 
-	SetBackHandler(ReloadMainBuckets, deferFunc)
-	RenderBucketExplorerListing(bucket, listing, selection, deferFunc)
+	explorer := &BucketExplorer{
+		bucket:      bucket,
+		tree:        tree,
+		deferFunc:   deferFunc,
+		showDeleted: showDeleted,
+		marked:      make(map[*Node]bool),
+	}
+	RenderBucketExplorerListing(explorer, tree, selection)
 
 }
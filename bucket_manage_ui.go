@@ -0,0 +1,187 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gizak/termui"
+)
+
+func RenderCreateBucket(name string, back func()) {
+
+	// Walk through the new bucket options one prompt at a time
+
+	RenderInputPrompt("New bucket name", name, func(input string) {
+		if input == "" {
+			back()
+			return
+		}
+		err := ValidateBucketName(input)
+		if err != nil {
+			RenderError(err.Error())
+			RenderCreateBucket(input, back)
+			return
+		}
+		options := NewBucketOptions{Name: input}
+
+		regions := GetRegions()
+		RenderChoicePrompt(fmt.Sprintf("Region for %s", input), regions, func(choice int) {
+			options.Region = regions[choice]
+
+			RenderChoicePrompt("Versioning", []string{"Disabled", "Enabled"}, func(choice int) {
+				options.Versioning = choice == 1
+
+				encryptions := []string{"Account default", "SSE-S3 (AES256)", "SSE-KMS (aws/s3 key)"}
+				RenderChoicePrompt("Default encryption", encryptions, func(encryption int) {
+					switch encryption {
+					case 1:
+						options.Encryption = s3.ServerSideEncryptionAes256
+					case 2:
+						options.Encryption = s3.ServerSideEncryptionAwsKms
+					}
+
+					locks := []string{"Disabled", "Enabled (also enables versioning)"}
+					RenderChoicePrompt("Object lock", locks, func(choice int) {
+						options.ObjectLock = choice == 1
+						msg := fmt.Sprintf("Create %s in %s (versioning: %v, encryption: %s, object lock: %v)?",
+							options.Name, options.Region, options.Versioning || options.ObjectLock,
+							encryptions[encryption], options.ObjectLock)
+						RenderConfirmPrompt("Create Bucket", msg, func() {
+							ApplyCreateBucket(options, back)
+						}, back)
+					}, back)
+				}, back)
+			}, back)
+		}, back)
+	}, back)
+}
+
+func ApplyCreateBucket(options NewBucketOptions, back func()) {
+
+	// Create the bucket with a session in its region, then reload the list
+
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Creating %s in %s", options.Name, options.Region)))
+	sess, err := InitSession(options.Region)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	err = sess.CreateBucket(options)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		ReloadMainBuckets()
+		return
+	}
+	ReloadMainBuckets()
+}
+
+func RenderDeleteBucket(bucket BucketWithDisplay, back func()) {
+
+	// Refuse to delete a bucket with anything in it unless the user asks to
+	// empty it first. Either way they have to type the name to confirm.
+
+	name := *bucket.bucket.Name
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Checking if %s is empty", name)))
+	empty, err := sess.IsBucketEmpty(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	confirm := func(label string, onConfirm func()) {
+		RenderInputPrompt(label, "", func(input string) {
+			if input != name {
+				RenderError("Bucket name did not match, nothing deleted")
+				back()
+				return
+			}
+			onConfirm()
+		}, back)
+	}
+
+	if empty {
+		confirm(fmt.Sprintf("Type %s to delete it", name), func() {
+			ApplyDeleteBucket(sess, bucket, back)
+		})
+		return
+	}
+
+	msg := fmt.Sprintf("%s is not empty. Permanently delete every object version and delete marker, then the bucket?", name)
+	RenderConfirmPrompt("Bucket Not Empty", msg, func() {
+		confirm(fmt.Sprintf("Type %s to empty and delete it (this cannot be undone)", name), func() {
+			EmptyAndDeleteBucket(sess, bucket, back)
+		})
+	}, back)
+}
+
+func EmptyAndDeleteBucket(sess S3Session, bucket BucketWithDisplay, back func()) {
+
+	// Remove every version with progress, then the bucket itself
+
+	name := *bucket.bucket.Name
+	label := fmt.Sprintf("Emptying %s", name)
+	termui.Clear()
+	termui.Render(CreateStatusPrompt("Listing every object version"))
+	failures, err := sess.EmptyBucket(bucket, func(done int, total int) {
+		termui.Render(CreateProgressGauge(label, done, total))
+	})
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	if len(failures) > 0 {
+		lines := append([]string{fmt.Sprintf("%d versions could not be deleted, %s was not deleted:", len(failures), name)}, failures...)
+		RenderTextViewer("Empty Bucket Failures", lines, back)
+		return
+	}
+	ApplyDeleteBucket(sess, bucket, back)
+}
+
+func ApplyDeleteBucket(sess S3Session, bucket BucketWithDisplay, back func()) {
+
+	// Delete the bucket and reload the list
+
+	name := *bucket.bucket.Name
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Deleting %s", name)))
+	err := sess.DeleteBucket(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	ReloadMainBuckets()
+}
@@ -0,0 +1,143 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type BucketProperty struct {
+	Name  string
+	Fetch func(s S3Session, bucket *string) (interface{}, error)
+}
+
+// Error codes that just mean a configuration was never set
+
+var notConfiguredCodes = map[string]bool{
+	"NoSuchBucketPolicy":                             true,
+	"NoSuchCORSConfiguration":                        true,
+	"NoSuchLifecycleConfiguration":                   true,
+	"NoSuchWebsiteConfiguration":                     true,
+	"NoSuchTagSet":                                   true,
+	"NoSuchPublicAccessBlockConfiguration":           true,
+	"ServerSideEncryptionConfigurationNotFoundError": true,
+	"ReplicationConfigurationNotFoundError":          true,
+	"ObjectLockConfigurationNotFoundError":           true,
+}
+
+func IsNotConfiguredError(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return notConfiguredCodes[aerr.Code()]
+	}
+	return false
+}
+
+func GetBucketProperties() []BucketProperty {
+
+	// Every bucket level configuration we know how to show, in display order
+
+	return []BucketProperty{
+		{BUCKET_PROPERTY_VERSIONING, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_ENCRYPTION, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketEncryption(&s3.GetBucketEncryptionInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_PUBLIC_ACCESS, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_POLICY, func(s S3Session, bucket *string) (interface{}, error) {
+			out, err := s.S3Service.GetBucketPolicy(&s3.GetBucketPolicyInput{Bucket: bucket})
+			if err != nil {
+				return nil, err
+			}
+
+			// The policy is already a json document
+
+			return json.RawMessage(*out.Policy), nil
+		}},
+		{BUCKET_PROPERTY_ACL, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketAcl(&s3.GetBucketAclInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_CORS, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketCors(&s3.GetBucketCorsInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_LIFECYCLE, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_REPLICATION, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketReplication(&s3.GetBucketReplicationInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_LOGGING, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketLogging(&s3.GetBucketLoggingInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_WEBSITE, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketWebsite(&s3.GetBucketWebsiteInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_OBJECT_LOCK, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_REQUESTER_PAYS, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketRequestPayment(&s3.GetBucketRequestPaymentInput{Bucket: bucket})
+		}},
+		{BUCKET_PROPERTY_TAGS, func(s S3Session, bucket *string) (interface{}, error) {
+			return s.S3Service.GetBucketTagging(&s3.GetBucketTaggingInput{Bucket: bucket})
+		}},
+	}
+}
+
+func FormatJSONLines(value interface{}) (lines []string, err error) {
+
+	// Pretty print a value as indented json, one line per entry
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	var indented bytes.Buffer
+	err = json.Indent(&indented, data, "", "  ")
+	if err != nil {
+		return
+	}
+	lines = strings.Split(indented.String(), "\n")
+	return
+}
+
+func (s S3Session) GetBucketPropertyLines(bucket BucketWithDisplay, property BucketProperty) (lines []string, err error) {
+
+	// Fetch one property and format it for display
+
+	log.Printf("Fetching %s for bucket %s\n", property.Name, *bucket.bucket.Name)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	value, err := property.Fetch(s, bucket.bucket.Name)
+	if err != nil {
+		if IsNotConfiguredError(err) {
+			return []string{"Not configured"}, nil
+		}
+		return
+	}
+	return FormatJSONLines(value)
+}
@@ -0,0 +1,152 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gizak/termui"
+)
+
+func RenderBucketProperties(bucket BucketWithDisplay, back func()) {
+
+	// Open the properties dashboard with an empty cache
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	RenderBucketPropertyListing(bucket, sess, make(map[string][]string), 0, back)
+}
+
+func CreateBucketPropertyList(bucket BucketWithDisplay, properties []BucketProperty, cache map[string][]string, selection int) *termui.List {
+
+	// List the properties, with a one line summary of any already fetched
+
+	var displayStrings []string
+	for _, property := range properties {
+		summary := ""
+		if lines, ok := cache[property.Name]; ok && len(lines) == 1 {
+			summary = lines[0]
+		} else if ok {
+			summary = fmt.Sprintf("(%d lines)", len(lines))
+		}
+		displayStrings = append(displayStrings, fmt.Sprintf("%-22s %s", property.Name, summary))
+	}
+
+	listing, err := GetDirectoryDisplayListing(displayStrings, selection)
+	if err != nil {
+		RenderError(err.Error())
+		return &termui.List{}
+	}
+
+	ls := termui.NewList()
+	ls.Items = listing
+	ls.ItemFgColor = termui.ColorYellow
+	ls.BorderLabel = fmt.Sprintf("Properties: %s", bucket.displayString)
+	ls.Height = GetStringListHeight(displayStrings)
+	ls.Width = termui.TermWidth() - RIGHT_BUFFER
+	ls.Y = 0
+	return ls
+}
+
+func RenderBucketPropertyListing(bucket BucketWithDisplay, sess S3Session, cache map[string][]string, selection int, back func()) {
+
+	// Each property is fetched when it is first opened and then cached for
+	// as long as the dashboard is up
+
+	properties := GetBucketProperties()
+	help := RenderHelp("<e> edit", "<r> refresh")
+	render := func() {
+		termui.Render(CreateBucketPropertyList(bucket, properties, cache, selection), help)
+	}
+
+	termui.ResetHandlers()
+	termui.Clear()
+	render()
+	SetDefaultHandlers(func() { return })
+	SetBackHandler(back)
+
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		back()
+	})
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			render()
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(properties)-1 {
+			selection += 1
+			render()
+		}
+	})
+
+	// Enter fetches (if needed) and shows a property
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		property := properties[selection]
+		stay := func() {
+			RenderBucketPropertyListing(bucket, sess, cache, selection, back)
+		}
+
+		lines, ok := cache[property.Name]
+		if !ok {
+			termui.Render(CreateStatusPrompt(fmt.Sprintf("Fetching %s", property.Name)))
+			var err error
+			lines, err = sess.GetBucketPropertyLines(bucket, property)
+			if err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				return
+			}
+			cache[property.Name] = lines
+		}
+
+		title := fmt.Sprintf("%s: %s", property.Name, bucket.displayString)
+		RenderTextViewer(title, lines, stay)
+	})
+
+	// e edits the policy, CORS or lifecycle rules
+
+	termui.Handle("/sys/kbd/e", func(termui.Event) {
+		property := properties[selection]
+		document, ok := GetBucketDocument(property.Name)
+		if !ok {
+			RenderError(fmt.Sprintf("%s can't be edited here", property.Name))
+			return
+		}
+		RenderEditBucketDocument(bucket, sess, document, func() {
+			delete(cache, property.Name)
+			RenderBucketPropertyListing(bucket, sess, cache, selection, back)
+		})
+	})
+
+	// r refetches everything
+
+	termui.Handle("/sys/kbd/r", func(termui.Event) {
+		RenderBucketPropertyListing(bucket, sess, make(map[string][]string), selection, back)
+	})
+}
@@ -0,0 +1,194 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+var bucketNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+type NewBucketOptions struct {
+	Name       string
+	Region     string
+	Versioning bool
+	Encryption string // "" leaves the account default in place
+	ObjectLock bool
+}
+
+func GetRegions() []string {
+
+	// Regions offered when creating a bucket
+
+	return []string{
+		"us-east-1", "us-east-2", "us-west-1", "us-west-2",
+		"ca-central-1", "sa-east-1",
+		"eu-west-1", "eu-west-2", "eu-west-3", "eu-central-1", "eu-north-1", "eu-south-1",
+		"ap-south-1", "ap-northeast-1", "ap-northeast-2", "ap-northeast-3",
+		"ap-southeast-1", "ap-southeast-2", "ap-east-1",
+		"me-south-1", "af-south-1",
+	}
+}
+
+func ValidateBucketName(name string) (err error) {
+
+	// The S3 bucket naming rules
+
+	switch {
+	case len(name) < 3 || len(name) > 63:
+		err = errors.New("Bucket names must be between 3 and 63 characters long")
+	case !bucketNameRegex.MatchString(name):
+		err = errors.New("Bucket names may only contain lowercase letters, numbers, dots and hyphens, and must start and end with a letter or number")
+	case strings.Contains(name, ".."):
+		err = errors.New("Bucket names must not contain two adjacent periods")
+	case net.ParseIP(name) != nil:
+		err = errors.New("Bucket names must not be formatted as an IP address")
+	case strings.HasPrefix(name, "xn--") || strings.HasPrefix(name, "sthree-"):
+		err = errors.New("Bucket names must not start with xn-- or sthree-")
+	case strings.HasSuffix(name, "-s3alias") || strings.HasSuffix(name, "--ol-s3"):
+		err = errors.New("Bucket names must not end with -s3alias or --ol-s3")
+	}
+	return
+}
+
+func (s S3Session) CreateBucket(options NewBucketOptions) (err error) {
+
+	// Create a bucket in the session's region and apply any options.
+	// us-east-1 is the one region that must not be sent as a constraint.
+
+	log.Printf("Creating bucket: %+v\n", options)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	input := &s3.CreateBucketInput{
+		Bucket: aws.String(options.Name),
+	}
+	if options.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3.CreateBucketConfiguration{
+			LocationConstraint: aws.String(options.Region),
+		}
+	}
+	if options.ObjectLock {
+		input.ObjectLockEnabledForBucket = aws.Bool(true)
+	}
+	_, err = s.S3Service.CreateBucket(input)
+	if err != nil {
+		return
+	}
+	err = s.S3Service.WaitUntilBucketExists(&s3.HeadBucketInput{Bucket: input.Bucket})
+	if err != nil {
+		return
+	}
+
+	// Object lock turns versioning on by itself
+
+	if options.Versioning && !options.ObjectLock {
+		log.Printf("Enabling versioning on %s\n", options.Name)
+		_, err = s.S3Service.PutBucketVersioning(&s3.PutBucketVersioningInput{
+			Bucket: input.Bucket,
+			VersioningConfiguration: &s3.VersioningConfiguration{
+				Status: aws.String(s3.BucketVersioningStatusEnabled),
+			},
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	if options.Encryption != "" {
+		log.Printf("Setting %s default encryption on %s\n", options.Encryption, options.Name)
+		_, err = s.S3Service.PutBucketEncryption(&s3.PutBucketEncryptionInput{
+			Bucket: input.Bucket,
+			ServerSideEncryptionConfiguration: &s3.ServerSideEncryptionConfiguration{
+				Rules: []*s3.ServerSideEncryptionRule{
+					{
+						ApplyServerSideEncryptionByDefault: &s3.ServerSideEncryptionByDefault{
+							SSEAlgorithm: aws.String(options.Encryption),
+						},
+					},
+				},
+			},
+		})
+	}
+	return
+}
+
+func (s S3Session) IsBucketEmpty(bucket BucketWithDisplay) (empty bool, err error) {
+
+	// A bucket is only empty when it has no versions or delete markers left
+
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	empty = true
+	err = s.S3Service.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket:  bucket.bucket.Name,
+		MaxKeys: aws.Int64(1),
+	},
+		func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+			if len(page.Versions) > 0 || len(page.DeleteMarkers) > 0 {
+				empty = false
+			}
+			return false
+		})
+	return
+}
+
+func (s S3Session) EmptyBucket(bucket BucketWithDisplay, progress func(done int, total int)) (failures []string, err error) {
+
+	// Permanently delete every version and delete marker in a bucket
+
+	versions, err := s.GetPrefixVersions(bucket, "")
+	if err != nil {
+		return
+	}
+	var ids []*s3.ObjectIdentifier
+	for _, version := range versions {
+		ids = append(ids, &s3.ObjectIdentifier{
+			Key:       aws.String(version.Key),
+			VersionId: aws.String(version.VersionId),
+		})
+	}
+	log.Printf("Emptying bucket %s of %d versions\n", *bucket.bucket.Name, len(ids))
+	failures, err = s.DeleteObjectBatchWithProgress(bucket, ids, progress)
+	if progress != nil {
+		progress(len(ids), len(ids))
+	}
+	return
+}
+
+func (s S3Session) DeleteBucket(bucket BucketWithDisplay) (err error) {
+
+	// Delete an (already empty) bucket
+
+	log.Printf("Deleting bucket: %s\n", *bucket.bucket.Name)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	_, err = s.S3Service.DeleteBucket(&s3.DeleteBucketInput{
+		Bucket: bucket.bucket.Name,
+	})
+	return
+}
@@ -0,0 +1,52 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBucketName(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"my-bucket", true},
+		{"my.bucket.2024", true},
+		{"abc", true},
+		{strings.Repeat("a", 63), true},
+		{"ab", false},
+		{strings.Repeat("a", 64), false},
+		{"My-Bucket", false},
+		{"-bucket", false},
+		{"bucket-", false},
+		{"bucket_name", false},
+		{"my..bucket", false},
+		{"192.168.1.1", false},
+		{"xn--bucket", false},
+		{"sthree-bucket", false},
+		{"bucket-s3alias", false},
+		{"bucket--ol-s3", false},
+	}
+	for _, test := range tests {
+		if err := ValidateBucketName(test.name); (err == nil) != test.ok {
+			t.Errorf("%q: got error %v, want ok %v", test.name, err, test.ok)
+		}
+	}
+}
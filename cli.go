@@ -0,0 +1,275 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const s3UrlScheme = "s3://"
+
+type Command struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(args []string) int
+}
+
+// Error codes that mean the bucket or key isn't there
+
+var notFoundCodes = map[string]bool{
+	"NotFound":     true,
+	"NoSuchKey":    true,
+	"NoSuchBucket": true,
+}
+
+// A flag that can be given more than once
+
+type StringListFlag []string
+
+func (f *StringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *StringListFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func GetCommands() []Command {
+
+	// Every subcommand, in the order they are listed in the usage
+
+	return []Command{
+		{"ls", "ls [-r] [-l] [-h] [s3://bucket[/prefix]]", "List buckets, or the objects under a prefix", RunLsCommand},
+		{"get", "get [-r] s3://bucket/key [destination]", "Download an object, or a prefix with -r", RunGetCommand},
+		{"put", "put [-r] source s3://bucket/key", "Upload a file, or a directory with -r", RunPutCommand},
+		{"cp", "cp [-r] source destination", "Copy between S3 locations, or to and from local paths", RunCpCommand},
+		{"rm", "rm [-r [-all]] s3://bucket/key", "Delete an object, or everything under a prefix with -r", RunRmCommand},
+		{"stat", "stat s3://bucket[/key]", "Show an object's metadata, or a bucket's region", RunStatCommand},
+		{"cat", "cat s3://bucket/key", "Write an object to stdout", RunCatCommand},
+		{"compare", "compare [-a] [-metadata] [-copy-missing] [-copy-extra] [-source-profile p] [-dest-profile p] s3://source s3://destination", "Compare two prefixes, optionally copying missing objects", RunCompareCommand},
+		{"sync", "sync [-mode m] [-delete] [-include glob] [-exclude glob] [-checksum] [-dry-run] [-parallel n] dir s3://bucket[/prefix]", "Sync a local directory with a prefix", RunSyncCommand},
+	}
+}
+
+func PrintUsage() {
+
+	// Usage for the flags and the subcommands
+
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command [args]]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Without a command the interactive explorer is started.")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nCommands:")
+	for _, command := range GetCommands() {
+		fmt.Fprintf(out, "  %-45s %s\n", command.Usage, command.Description)
+	}
+}
+
+func RunCommand(args []string) int {
+
+	// Find and run a subcommand, returning the exit code
+
+	log.Printf("Running command: %v\n", args)
+	commandOutput = NewOutputWriter(outputFormat, os.Stdout, os.Stderr)
+	if !IsOutputFormat(outputFormat) {
+		commandOutput = NewOutputWriter(DEFAULT_OUTPUT_FORMAT, os.Stdout, os.Stderr)
+		return ReportError("Usage", fmt.Sprintf("Unknown output format %s", outputFormat), EXIT_FAILED_USAGE)
+	}
+	defer commandOutput.Flush()
+
+	for _, command := range GetCommands() {
+		if command.Name == args[0] {
+			return command.Run(args[1:])
+		}
+	}
+	if args[0] == "help" {
+		PrintUsage()
+		return EXIT_USER_REQUESTED
+	}
+	if !commandOutput.IsStructured() {
+		defer PrintUsage()
+	}
+	return ReportError("Usage", fmt.Sprintf("Unknown command %s", args[0]), EXIT_FAILED_USAGE)
+}
+
+func NewCommandFlags(name string) *flag.FlagSet {
+
+	// Flag set for a subcommand that prints its own usage line
+
+	var usage string
+	for _, command := range GetCommands() {
+		if command.Name == name {
+			usage = command.Usage
+		}
+	}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	if commandOutput.IsStructured() {
+		flags.SetOutput(ioutil.Discard) // reported by FlagError instead
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s\n", os.Args[0], usage)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+func FlagError(err error) int {
+
+	// Outside the json formats the flag package has already printed the
+	// problem and the usage
+
+	if err == flag.ErrHelp {
+		return EXIT_USER_REQUESTED
+	}
+	if !commandOutput.IsStructured() {
+		return EXIT_FAILED_USAGE
+	}
+	return ReportError("Usage", err.Error(), EXIT_FAILED_USAGE)
+}
+
+func IsS3Url(arg string) bool {
+	return strings.HasPrefix(arg, s3UrlScheme)
+}
+
+func ParseS3Url(arg string) (bucket string, key string, err error) {
+
+	// Split s3://bucket/key into its bucket and key
+
+	if !IsS3Url(arg) {
+		err = errors.New(fmt.Sprintf("Not an S3 url: %s", arg))
+		return
+	}
+	path := strings.TrimPrefix(arg, s3UrlScheme)
+	parts := strings.SplitN(path, "/", 2)
+	bucket = parts[0]
+	if len(parts) == 2 {
+		key = parts[1]
+	}
+	if bucket == "" {
+		err = errors.New(fmt.Sprintf("No bucket in S3 url: %s", arg))
+	}
+	return
+}
+
+func FormatS3Url(bucket string, key string) string {
+	return s3UrlScheme + bucket + "/" + key
+}
+
+func ResolveBucket(name string) (sess S3Session, bucket BucketWithDisplay, err error) {
+	return ResolveProfileBucket(name, "")
+}
+
+func ResolveProfileBucket(name string, profile string) (sess S3Session, bucket BucketWithDisplay, err error) {
+
+	// Look up a bucket's region and open a session there, with the named
+	// credentials profile if one is given. Without a profile, local bucket
+	// names are tried first.
+
+	if profile == "" && localBackend.HasBucket(name) {
+		bucket = NewLocalBucket(&s3.Bucket{Name: aws.String(name)})
+		sess, err = InitBucketSession(bucket)
+		return
+	}
+	lookup := s3Session
+	if profile != "" {
+		lookup, err = InitProfileSession(DEFAULT_REGION, profile)
+		if err != nil {
+			return
+		}
+	}
+	region, err := lookup.GetBucketRegion(&s3.Bucket{Name: aws.String(name)})
+	if err != nil {
+		return
+	}
+	bucket = BucketWithDisplay{
+		bucket:        &s3.Bucket{Name: aws.String(name)},
+		displayString: fmt.Sprintf("%s (%s)", name, region),
+		region:        region,
+	}
+	sess, err = InitProfileSession(region, profile)
+	return
+}
+
+func ResolveS3Url(arg string) (sess S3Session, bucket BucketWithDisplay, key string, err error) {
+
+	// Parse an S3 url and resolve its bucket in one go
+
+	name, key, err := ParseS3Url(arg)
+	if err != nil {
+		return
+	}
+	sess, bucket, err = ResolveBucket(name)
+	return
+}
+
+func IsNotFoundError(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		return notFoundCodes[aerr.Code()]
+	}
+	return os.IsNotExist(err)
+}
+
+func ReportError(code string, message string, exitCode int) int {
+	log.Printf("Command failed (%s): %s\n", code, message)
+	commandOutput.WriteError(code, message, exitCode)
+	return exitCode
+}
+
+func CommandError(err error) int {
+
+	// Report an error and map it to an exit code
+
+	code := "Error"
+	if aerr, ok := err.(awserr.Error); ok {
+		code = aerr.Code()
+	} else if os.IsNotExist(err) {
+		code = "NotFound"
+	}
+	if IsNotFoundError(err) {
+		return ReportError(code, err.Error(), EXIT_FAILED_NOT_FOUND)
+	}
+	return ReportError(code, err.Error(), EXIT_FAILED_COMMAND)
+}
+
+func NotFoundError(message string) int {
+	return ReportError("NotFound", message, EXIT_FAILED_NOT_FOUND)
+}
+
+func UsageError(flags *flag.FlagSet, msg string) int {
+
+	// Report bad arguments along with the subcommand's usage
+
+	ReportError("Usage", msg, EXIT_FAILED_USAGE)
+	if !commandOutput.IsStructured() {
+		flags.Usage()
+	}
+	return EXIT_FAILED_USAGE
+}
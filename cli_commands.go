@@ -0,0 +1,666 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+const listingTimeFormat = "2006-01-02 15:04:05"
+
+func RunLsCommand(args []string) int {
+	flags := NewCommandFlags("ls")
+	recursive := flags.Bool("r", false, "List every key under the prefix instead of stopping at the next /")
+	long := flags.Bool("l", false, "Show modified time, size and storage class")
+	human := flags.Bool("h", false, "Show sizes in human readable units")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() > 1 {
+		return UsageError(flags, "Too many arguments")
+	}
+
+	// Without a url list the buckets. Looking up every region is slow, so
+	// only do it when the region is going to be shown.
+
+	if flags.NArg() == 0 {
+		buckets, err := s3Session.GetBucketListing()
+		if err != nil {
+			return CommandError(err)
+		}
+		for _, bucket := range buckets {
+			record := ObjectRecord{
+				Type:         RECORD_TYPE_BUCKET,
+				Bucket:       *bucket.Name,
+				LastModified: FormatRecordTime(aws.TimeValue(bucket.CreationDate)),
+				long:         *long,
+			}
+			if *long || outputFormat != OUTPUT_FORMAT_TABLE {
+				record.Region, _ = s3Session.GetBucketRegion(bucket)
+			}
+			commandOutput.Write(record)
+		}
+
+		// Local buckets come after the real ones
+
+		for _, bucket := range GetLocalBuckets() {
+			commandOutput.Write(ObjectRecord{
+				Type:         RECORD_TYPE_BUCKET,
+				Bucket:       *bucket.bucket.Name,
+				Region:       bucket.region,
+				LastModified: FormatRecordTime(aws.TimeValue(bucket.bucket.CreationDate)),
+				long:         *long,
+			})
+		}
+		return EXIT_USER_REQUESTED
+	}
+
+	sess, bucket, prefix, err := ResolveS3Url(flags.Arg(0))
+	if err != nil {
+		return CommandError(err)
+	}
+	objects, prefixes, err := sess.ListPrefix(bucket, prefix, *recursive)
+	if err != nil {
+		return CommandError(err)
+	}
+	if prefix != "" && len(objects) == 0 && len(prefixes) == 0 {
+		return NotFoundError(fmt.Sprintf("Nothing found at %s", flags.Arg(0)))
+	}
+
+	// Sub-prefixes first, like a directory listing
+
+	for _, p := range prefixes {
+		commandOutput.Write(ObjectRecord{
+			Type:   RECORD_TYPE_PREFIX,
+			Bucket: *bucket.bucket.Name,
+			Key:    p,
+			Region: bucket.region,
+			long:   *long,
+		})
+	}
+	for _, object := range objects {
+		record := GetObjectRecord(bucket, object)
+		record.long = *long
+		record.human = *human
+		commandOutput.Write(record)
+	}
+	return EXIT_USER_REQUESTED
+}
+
+func GetObjectRecord(bucket BucketWithDisplay, object *s3.Object) ObjectRecord {
+	return ObjectRecord{
+		Type:         RECORD_TYPE_OBJECT,
+		Bucket:       *bucket.bucket.Name,
+		Key:          *object.Key,
+		Size:         aws.Int64Value(object.Size),
+		LastModified: FormatRecordTime(aws.TimeValue(object.LastModified)),
+		ETag:         strings.Trim(aws.StringValue(object.ETag), "\""),
+		StorageClass: GetStorageClassDisplay(object),
+		Region:       bucket.region,
+	}
+}
+
+func RunGetCommand(args []string) int {
+	flags := NewCommandFlags("get")
+	recursive := flags.Bool("r", false, "Download everything under the prefix")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return UsageError(flags, "Expected an S3 url and an optional destination")
+	}
+	if !IsS3Url(flags.Arg(0)) {
+		return UsageError(flags, fmt.Sprintf("Not an S3 url: %s", flags.Arg(0)))
+	}
+	dest := flags.Arg(1)
+	if dest == "" {
+		dest = "."
+	}
+	return GetObjects(flags.Arg(0), dest, *recursive)
+}
+
+func RunPutCommand(args []string) int {
+	flags := NewCommandFlags("put")
+	recursive := flags.Bool("r", false, "Upload a directory and everything in it")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 2 {
+		return UsageError(flags, "Expected a source and an S3 url")
+	}
+	if !IsS3Url(flags.Arg(1)) {
+		return UsageError(flags, fmt.Sprintf("Not an S3 url: %s", flags.Arg(1)))
+	}
+	return PutObjects(flags.Arg(0), flags.Arg(1), *recursive)
+}
+
+func RunCpCommand(args []string) int {
+	flags := NewCommandFlags("cp")
+	recursive := flags.Bool("r", false, "Copy everything under the prefix or directory")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 2 {
+		return UsageError(flags, "Expected a source and a destination")
+	}
+
+	// Hand local transfers to get and put
+
+	src, dest := flags.Arg(0), flags.Arg(1)
+	switch {
+	case IsS3Url(src) && IsS3Url(dest):
+		return CopyObjects(src, dest, *recursive)
+	case IsS3Url(src):
+		return GetObjects(src, dest, *recursive)
+	case IsS3Url(dest):
+		return PutObjects(src, dest, *recursive)
+	default:
+		return UsageError(flags, "At least one of source and destination must be an S3 url")
+	}
+}
+
+func RunRmCommand(args []string) int {
+	flags := NewCommandFlags("rm")
+	recursive := flags.Bool("r", false, "Delete everything under the prefix")
+	wholeBucket := flags.Bool("all", false, "With -r, allow deleting everything in the bucket")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 1 {
+		return UsageError(flags, "Expected one S3 url")
+	}
+	sess, bucket, key, err := ResolveS3Url(flags.Arg(0))
+	if err != nil {
+		return CommandError(err)
+	}
+	name := *bucket.bucket.Name
+
+	if !*recursive {
+		if key == "" {
+			return UsageError(flags, "Refusing to delete a whole bucket without -r")
+		}
+
+		// Deleting a missing key succeeds, so check first to report it
+
+		if _, err = sess.HeadObject(bucket, key); err != nil {
+			return CommandError(err)
+		}
+		if err = sess.DeleteObject(bucket, key); err != nil {
+			return CommandError(err)
+		}
+		commandOutput.Write(ActionRecord{Action: "delete", Source: FormatS3Url(name, key)})
+		return EXIT_USER_REQUESTED
+	}
+
+	// Only whole prefixes, so logs doesn't take logs-old/ and logs.txt with it
+
+	if key == "" && !*wholeBucket {
+		return UsageError(flags, "Refusing to delete everything in a bucket without -all")
+	}
+	objects, _, err := sess.ListPrefix(bucket, GetSyncPrefix(key), true)
+	if err != nil {
+		return CommandError(err)
+	}
+	if len(objects) == 0 {
+		return NotFoundError(fmt.Sprintf("Nothing found at %s", flags.Arg(0)))
+	}
+	var ids []*s3.ObjectIdentifier
+	for _, object := range objects {
+		ids = append(ids, &s3.ObjectIdentifier{Key: object.Key})
+	}
+	errs, err := sess.DeleteObjectBatchErrors(bucket, ids, nil)
+	if err != nil {
+		return CommandError(err)
+	}
+
+	// Report what was deleted and what wasn't
+
+	failed := make(map[string]bool)
+	for _, e := range errs {
+		failed[aws.StringValue(e.Key)] = true
+		ReportError(aws.StringValue(e.Code),
+			fmt.Sprintf("Failed to delete %s: %s", FormatS3Url(name, aws.StringValue(e.Key)), aws.StringValue(e.Message)),
+			EXIT_FAILED_COMMAND)
+	}
+	for _, object := range objects {
+		if !failed[*object.Key] {
+			commandOutput.Write(ActionRecord{Action: "delete", Source: FormatS3Url(name, *object.Key)})
+		}
+	}
+	if len(errs) > 0 {
+		return EXIT_FAILED_COMMAND
+	}
+	return EXIT_USER_REQUESTED
+}
+
+func RunStatCommand(args []string) int {
+	flags := NewCommandFlags("stat")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 1 {
+		return UsageError(flags, "Expected one S3 url")
+	}
+	sess, bucket, key, err := ResolveS3Url(flags.Arg(0))
+	if err != nil {
+		return CommandError(err)
+	}
+
+	// A bare bucket only has a region to show
+
+	if key == "" {
+		commandOutput.Write(ObjectDetailRecord{ObjectRecord: ObjectRecord{
+			Type:   RECORD_TYPE_BUCKET,
+			Bucket: *bucket.bucket.Name,
+			Region: bucket.region,
+		}})
+		return EXIT_USER_REQUESTED
+	}
+
+	out, err := sess.HeadObject(bucket, key)
+	if err != nil {
+		return CommandError(err)
+	}
+	record := ObjectDetailRecord{
+		ObjectRecord: GetObjectRecord(bucket, &s3.Object{
+			Key:          aws.String(key),
+			Size:         out.ContentLength,
+			LastModified: out.LastModified,
+			ETag:         out.ETag,
+			StorageClass: out.StorageClass,
+		}),
+		ContentType:          aws.StringValue(out.ContentType),
+		VersionId:            aws.StringValue(out.VersionId),
+		ServerSideEncryption: aws.StringValue(out.ServerSideEncryption),
+		Restore:              aws.StringValue(out.Restore),
+		Metadata:             aws.StringValueMap(out.Metadata),
+	}
+	commandOutput.Write(record)
+	return EXIT_USER_REQUESTED
+}
+
+func RunCatCommand(args []string) int {
+	flags := NewCommandFlags("cat")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 1 {
+		return UsageError(flags, "Expected one S3 url")
+	}
+	sess, bucket, key, err := ResolveS3Url(flags.Arg(0))
+	if err != nil {
+		return CommandError(err)
+	}
+	body, _, err := sess.GetObjectVersionReader(bucket, key, "")
+	if err != nil {
+		return CommandError(err)
+	}
+	defer body.Close()
+	if _, err = io.Copy(os.Stdout, body); err != nil {
+		return CommandError(err)
+	}
+	return EXIT_USER_REQUESTED
+}
+
+func RunSyncCommand(args []string) int {
+	flags := NewCommandFlags("sync")
+	var include, exclude StringListFlag
+	options := SyncOptions{}
+	flags.StringVar(&options.Mode, "mode", SYNC_MODE_UPLOAD, "upload, download or mirror (both ways)")
+	flags.BoolVar(&options.Delete, "delete", false, "Propagate deletes to the other side")
+	flags.Var(&include, "include", "Only sync paths matching this glob (repeatable)")
+	flags.Var(&exclude, "exclude", "Skip paths matching this glob (repeatable)")
+	flags.BoolVar(&options.Checksum, "checksum", false, "Compare content against ETags instead of modification times")
+	flags.BoolVar(&options.DryRun, "dry-run", false, "Print what would be done without changing anything")
+	flags.IntVar(&options.Parallel, "parallel", DEFAULT_SYNC_PARALLEL, "Transfers to run at once")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 2 || !IsS3Url(flags.Arg(1)) || IsS3Url(flags.Arg(0)) {
+		return UsageError(flags, "Expected a local directory and an S3 url")
+	}
+	if !IsSyncMode(options.Mode) {
+		return UsageError(flags, fmt.Sprintf("Unknown mode %s", options.Mode))
+	}
+	options.Include, options.Exclude = include, exclude
+	root, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		return CommandError(err)
+	}
+	if _, err = os.Stat(root); os.IsNotExist(err) && (options.Mode != SYNC_MODE_DOWNLOAD || options.DryRun) {
+		return NotFoundError(fmt.Sprintf("%s does not exist", root))
+	}
+	sess, bucket, key, err := ResolveS3Url(flags.Arg(1))
+	if err != nil {
+		return CommandError(err)
+	}
+
+	// Report each action as it finishes
+
+	code := EXIT_USER_REQUESTED
+	report, err := sess.RunSync(bucket, root, GetSyncPrefix(key), options, func(action SyncAction, err error, done int, total int) {
+		if err != nil {
+			errCode := "Error"
+			if aerr, ok := err.(awserr.Error); ok {
+				errCode = aerr.Code()
+			}
+			code = ReportError(errCode, fmt.Sprintf("Failed to %s %s: %s", action.Action, action.RelPath, err), EXIT_FAILED_COMMAND)
+			return
+		}
+		source, destination := GetSyncActionLocations(bucket, action)
+		commandOutput.Write(SyncActionRecord{
+			Action:      action.Action,
+			Source:      source,
+			Destination: destination,
+			Reason:      action.Reason,
+			Size:        action.Size,
+			DryRun:      options.DryRun,
+		})
+	})
+	if err != nil {
+		return CommandError(err)
+	}
+
+	// The summary has other columns, so csv output is the actions only
+
+	if outputFormat != OUTPUT_FORMAT_CSV {
+		commandOutput.Write(NewSyncReportRecord(report))
+	}
+	return code
+}
+
+func RunCompareCommand(args []string) int {
+	flags := NewCommandFlags("compare")
+	all := flags.Bool("a", false, "Also list matching objects")
+	metadata := flags.Bool("metadata", false, "Also compare content headers and user metadata")
+	copyMissing := flags.Bool("copy-missing", false, "Copy objects missing from the destination")
+	copyExtra := flags.Bool("copy-extra", false, "Copy objects only in the destination back to the source")
+	srcProfile := flags.String("source-profile", "", "Credentials profile for the source")
+	destProfile := flags.String("dest-profile", "", "Credentials profile for the destination")
+	if err := flags.Parse(args); err != nil {
+		return FlagError(err)
+	}
+	if flags.NArg() != 2 || !IsS3Url(flags.Arg(0)) || !IsS3Url(flags.Arg(1)) {
+		return UsageError(flags, "Expected two S3 urls")
+	}
+	src, err := ResolveCompareLocation(flags.Arg(0), *srcProfile)
+	if err != nil {
+		return CommandError(err)
+	}
+	dest, err := ResolveCompareLocation(flags.Arg(1), *destProfile)
+	if err != nil {
+		return CommandError(err)
+	}
+	comparisons, failures, err := CompareLocations(src, dest, *metadata, nil)
+	if err != nil {
+		return CommandError(err)
+	}
+	code := EXIT_USER_REQUESTED
+	for _, failure := range failures {
+		code = ReportError("Error", fmt.Sprintf("Could not compare metadata of %s", failure), EXIT_FAILED_COMMAND)
+	}
+
+	// Report each key, copying the missing ones if asked to
+
+	differences := false
+	for _, comparison := range comparisons {
+		if comparison.Status == COMPARE_MATCHED && !*all {
+			continue
+		}
+		record := CompareRecord{
+			Status:      comparison.Status,
+			Key:         comparison.RelPath,
+			Source:      FormatS3Url(*src.Bucket.bucket.Name, src.Prefix+comparison.RelPath),
+			Destination: FormatS3Url(*dest.Bucket.bucket.Name, dest.Prefix+comparison.RelPath),
+			Reasons:     comparison.Reasons,
+		}
+		if record.Reasons == nil {
+			record.Reasons = []string{}
+		}
+		if comparison.Source != nil {
+			record.SourceSize = aws.Int64Value(comparison.Source.Size)
+			record.SourceETag = GetObjectETag(comparison.Source)
+		}
+		if comparison.Dest != nil {
+			record.DestSize = aws.Int64Value(comparison.Dest.Size)
+			record.DestETag = GetObjectETag(comparison.Dest)
+		}
+		switch {
+		case comparison.Status == COMPARE_MISSING && *copyMissing:
+			if err := CopyComparedObject(src, dest, comparison.RelPath, record.SourceSize); err != nil {
+				code = CommandError(err)
+				differences = true
+			} else {
+				record.Copied = "to_destination"
+			}
+		case comparison.Status == COMPARE_EXTRA && *copyExtra:
+			if err := CopyComparedObject(dest, src, comparison.RelPath, record.DestSize); err != nil {
+				code = CommandError(err)
+				differences = true
+			} else {
+				record.Copied = "to_source"
+			}
+		case comparison.Status != COMPARE_MATCHED:
+			differences = true
+		}
+		commandOutput.Write(record)
+	}
+	if code == EXIT_USER_REQUESTED && differences {
+		code = EXIT_FOUND_DIFFERENCES
+	}
+	return code
+}
+
+func GetObjects(src string, dest string, recursive bool) int {
+
+	// Download a key, or every key under a prefix, to a local path
+
+	sess, bucket, key, err := ResolveS3Url(src)
+	if err != nil {
+		return CommandError(err)
+	}
+	isDir := strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, localDelimiter)
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return CommandError(err)
+	}
+	name := *bucket.bucket.Name
+
+	if !recursive {
+		if key == "" || strings.HasSuffix(key, "/") {
+			return ReportError("Usage", fmt.Sprintf("%s is a prefix, use -r to download it", src), EXIT_FAILED_USAGE)
+		}
+		if info, err := os.Stat(dest); isDir || (err == nil && info.IsDir()) {
+			dest = filepath.Join(dest, path.Base(key))
+		}
+		return DownloadCommandObject(sess, bucket, key, dest)
+	}
+
+	// Keep the layout below the prefix
+
+	prefix := key
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	objects, _, err := sess.ListPrefix(bucket, prefix, true)
+	if err != nil {
+		return CommandError(err)
+	}
+	if len(objects) == 0 {
+		return NotFoundError(fmt.Sprintf("Nothing found at %s", FormatS3Url(name, prefix)))
+	}
+	code := EXIT_USER_REQUESTED
+	for _, object := range objects {
+		if strings.HasSuffix(*object.Key, "/") {
+			continue
+		}
+		rel := strings.TrimPrefix(*object.Key, prefix)
+		if result := DownloadCommandObject(sess, bucket, *object.Key, filepath.Join(dest, filepath.FromSlash(rel))); result != EXIT_USER_REQUESTED {
+			code = result
+		}
+	}
+	return code
+}
+
+func DownloadCommandObject(sess S3Session, bucket BucketWithDisplay, key string, dest string) int {
+
+	// Download one key. A failed download leaves any existing file alone.
+
+	err := sess.DownloadObjectVersion(bucket, key, "", dest)
+	if err != nil {
+		return CommandError(err)
+	}
+	commandOutput.Write(ActionRecord{Action: "download", Source: FormatS3Url(*bucket.bucket.Name, key), Destination: dest})
+	return EXIT_USER_REQUESTED
+}
+
+func PutObjects(src string, dest string, recursive bool) int {
+
+	// Upload a file, or a directory tree, to a key or prefix
+
+	sess, bucket, key, err := ResolveS3Url(dest)
+	if err != nil {
+		return CommandError(err)
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return CommandError(err)
+	}
+	name := *bucket.bucket.Name
+
+	if !info.IsDir() {
+		if key == "" || strings.HasSuffix(key, "/") {
+			key += filepath.Base(src)
+		}
+		if err = sess.UploadFile(bucket, src, key); err != nil {
+			return CommandError(err)
+		}
+		commandOutput.Write(ActionRecord{Action: "upload", Source: src, Destination: FormatS3Url(name, key)})
+		return EXIT_USER_REQUESTED
+	}
+	if !recursive {
+		return ReportError("Usage", fmt.Sprintf("%s is a directory, use -r to upload it", src), EXIT_FAILED_USAGE)
+	}
+
+	// Keys mirror the paths below the directory
+
+	prefix := key
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	code := EXIT_USER_REQUESTED
+	err = filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		objectKey := prefix + filepath.ToSlash(rel)
+		if err := sess.UploadFile(bucket, file, objectKey); err != nil {
+			code = CommandError(err)
+			return nil
+		}
+		commandOutput.Write(ActionRecord{Action: "upload", Source: file, Destination: FormatS3Url(name, objectKey)})
+		return nil
+	})
+	if err != nil {
+		return CommandError(err)
+	}
+	return code
+}
+
+func CopyObjects(src string, dest string, recursive bool) int {
+
+	// Server side copy of a key, or every key under a prefix
+
+	_, srcBucket, srcKey, err := ResolveS3Url(src)
+	if err != nil {
+		return CommandError(err)
+	}
+	sess, destBucket, destKey, err := ResolveS3Url(dest)
+	if err != nil {
+		return CommandError(err)
+	}
+
+	if !recursive {
+		if srcKey == "" || strings.HasSuffix(srcKey, "/") {
+			return ReportError("Usage", fmt.Sprintf("%s is a prefix, use -r to copy it", src), EXIT_FAILED_USAGE)
+		}
+		if destKey == "" || strings.HasSuffix(destKey, "/") {
+			destKey += path.Base(srcKey)
+		}
+		return CopyCommandObject(sess, srcBucket, srcKey, destBucket, destKey)
+	}
+
+	srcPrefix, destPrefix := srcKey, destKey
+	if srcPrefix != "" && !strings.HasSuffix(srcPrefix, "/") {
+		srcPrefix += "/"
+	}
+	if destPrefix != "" && !strings.HasSuffix(destPrefix, "/") {
+		destPrefix += "/"
+	}
+
+	// List with a session in the source's region
+
+	srcSess, err := InitBucketSession(srcBucket)
+	if err != nil {
+		return CommandError(err)
+	}
+	objects, _, err := srcSess.ListPrefix(srcBucket, srcPrefix, true)
+	if err != nil {
+		return CommandError(err)
+	}
+	if len(objects) == 0 {
+		return NotFoundError(fmt.Sprintf("Nothing found at %s", FormatS3Url(*srcBucket.bucket.Name, srcPrefix)))
+	}
+	code := EXIT_USER_REQUESTED
+	for _, object := range objects {
+		rel := strings.TrimPrefix(*object.Key, srcPrefix)
+		if result := CopyCommandObject(sess, srcBucket, *object.Key, destBucket, destPrefix+rel); result != EXIT_USER_REQUESTED {
+			code = result
+		}
+	}
+	return code
+}
+
+func CopyCommandObject(sess S3Session, srcBucket BucketWithDisplay, srcKey string, destBucket BucketWithDisplay, destKey string) int {
+	err := sess.CopyObject(srcBucket, srcKey, destBucket, destKey)
+	if err != nil {
+		return CommandError(err)
+	}
+	commandOutput.Write(ActionRecord{
+		Action:      "copy",
+		Source:      FormatS3Url(*srcBucket.bucket.Name, srcKey),
+		Destination: FormatS3Url(*destBucket.bucket.Name, destKey),
+	})
+	return EXIT_USER_REQUESTED
+}
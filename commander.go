@@ -0,0 +1,311 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// One side of the commander: a bucket prefix or a local directory
+
+type Pane struct {
+	Local     bool
+	Bucket    BucketWithDisplay
+	Session   S3Session
+	Tree      *Node // the whole bucket, unused for local panes
+	Dir       *Node
+	Selection int
+	Marked    map[*Node]bool
+	deferFunc func() // removes a bucket pane's mock filesystem
+}
+
+// One file to copy or move between panes. Paths are keys on the bucket
+// side and absolute paths on the local side.
+
+type PaneTransfer struct {
+	Source      string
+	Destination string
+	Size        int64
+}
+
+func NewLocalPane(dir string) (pane *Pane, err error) {
+	node, err := LoadLocalDirectory(dir)
+	if err != nil {
+		return
+	}
+	pane = &Pane{Local: true, Dir: node, Marked: make(map[*Node]bool)}
+	return
+}
+
+func NewBucketPane(bucket BucketWithDisplay) (pane *Pane, err error) {
+
+	// List the bucket in its region and index it
+
+	bucket = GetResolvedBucket(bucket)
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		return
+	}
+	objects, err := sess.GetBucketObjects(bucket)
+	if err != nil {
+		return
+	}
+	tree, deferFunc, err := BuildBucketTree(objects)
+	if err != nil {
+		return
+	}
+	pane = &Pane{
+		Bucket:    bucket,
+		Session:   sess,
+		Tree:      tree,
+		Dir:       tree,
+		Marked:    make(map[*Node]bool),
+		deferFunc: deferFunc,
+	}
+	return
+}
+
+func (p *Pane) Close() {
+	if p.deferFunc != nil {
+		p.deferFunc()
+		p.deferFunc = nil
+	}
+}
+
+func (p *Pane) Location() string {
+	if p.Local {
+		return p.Dir.FullPath
+	}
+	return FormatS3Url(*p.Bucket.bucket.Name, GetNodePrefix(p.Dir))
+}
+
+func (p *Pane) Open(node *Node) (err error) {
+
+	// Change to a directory. Local panes read it fresh.
+
+	if p.Local {
+		dir, err := LoadLocalDirectory(node.FullPath)
+		if err != nil {
+			return err
+		}
+		p.Dir = dir
+	} else if node.DisplayString == ".." {
+		p.Dir = p.Dir.Parent
+	} else {
+		p.Dir = node
+	}
+	p.Selection = 0
+	p.Marked = make(map[*Node]bool)
+	return
+}
+
+func (p *Pane) Reload() (err error) {
+
+	// Re-read the pane, staying in the same directory if it still exists
+
+	p.Marked = make(map[*Node]bool)
+	if p.Local {
+		dir, err := LoadLocalDirectory(p.Dir.FullPath)
+		if err != nil {
+			return err
+		}
+		p.Dir = dir
+		return nil
+	}
+	prefix := GetNodePrefix(p.Dir)
+	objects, err := p.Session.GetBucketObjects(p.Bucket)
+	if err != nil {
+		return
+	}
+	tree, deferFunc, err := BuildBucketTree(objects)
+	if err != nil {
+		return
+	}
+	p.Close()
+	p.Tree, p.deferFunc = tree, deferFunc
+	p.Dir = FindDirNode(tree, prefix)
+	return
+}
+
+func (p *Pane) Targets() (targets []*Node) {
+
+	// The marked entries, or the selection (never "..")
+
+	if len(p.Marked) > 0 {
+		for node := range p.Marked {
+			targets = append(targets, node)
+		}
+		sort.Slice(targets, func(i, j int) bool {
+			return targets[i].FullPath < targets[j].FullPath
+		})
+		return
+	}
+	nodes := GetNodeDirectory(p.Dir)
+	if p.Selection < len(nodes) && nodes[p.Selection].DisplayString != ".." {
+		targets = append(targets, nodes[p.Selection])
+	}
+	return
+}
+
+func FindDirNode(tree *Node, prefix string) *Node {
+
+	// Walk down a tree along a prefix, as far as it still exists
+
+	dir := tree
+	for _, part := range strings.Split(strings.TrimSuffix(prefix, "/"), "/") {
+		found := false
+		for _, child := range dir.Children {
+			if child.Info.IsDir && child.Info.Name == part {
+				dir, found = child, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return dir
+}
+
+func GetPaneTransferAction(src *Pane, dest *Pane) string {
+
+	// What copying between two panes means for their types
+
+	switch {
+	case src.Local && dest.Local:
+		return "copy"
+	case src.Local:
+		return "upload"
+	case dest.Local:
+		return "download"
+	}
+	return "server side copy"
+}
+
+func GetPaneTransfers(src *Pane, dest *Pane, targets []*Node) (transfers []PaneTransfer, err error) {
+
+	// Every file under the targets, keeping its path relative to the
+	// source pane's directory
+
+	if src.Location() == dest.Location() {
+		return nil, errors.New("Both panes show the same directory")
+	}
+	add := func(rel string, source string, size int64) {
+		transfer := PaneTransfer{Source: source, Size: size}
+		if dest.Local {
+			transfer.Destination = filepath.Join(dest.Dir.FullPath, filepath.FromSlash(rel))
+		} else {
+			transfer.Destination = GetNodePrefix(dest.Dir) + rel
+		}
+		transfers = append(transfers, transfer)
+	}
+
+	if !src.Local {
+		prefix := GetNodePrefix(src.Dir)
+		for _, node := range GetUniqueFileNodes(targets) {
+			add(strings.TrimPrefix(*node.S3Object.Key, prefix), *node.S3Object.Key, aws.Int64Value(node.S3Object.Size))
+		}
+		return
+	}
+	for _, target := range targets {
+		err = filepath.Walk(target.FullPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(src.Dir.FullPath, path)
+			if err != nil {
+				return err
+			}
+			add(filepath.ToSlash(rel), path, info.Size())
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+func RunPaneTransfer(src *Pane, dest *Pane, transfer PaneTransfer) error {
+
+	// Upload, download, copy server side or copy locally
+
+	switch {
+	case src.Local && dest.Local:
+		return CopyLocalFile(transfer.Source, transfer.Destination)
+	case src.Local:
+		return dest.Session.UploadFile(dest.Bucket, transfer.Source, transfer.Destination)
+	case dest.Local:
+		return src.Session.DownloadObjectVersion(src.Bucket, transfer.Source, "", transfer.Destination)
+	}
+	if transfer.Size > MAX_COPY_OBJECT_SIZE {
+		return fmt.Errorf("%s is too large for a server side copy", transfer.Source)
+	}
+	return dest.Session.CopyObject(src.Bucket, transfer.Source, dest.Bucket, transfer.Destination)
+}
+
+func RemovePaneSource(src *Pane, transfer PaneTransfer) error {
+
+	// The second half of a move
+
+	if src.Local {
+		log.Printf("Removing moved file %s\n", transfer.Source)
+		return os.Remove(transfer.Source)
+	}
+	return src.Session.DeleteObject(src.Bucket, transfer.Source)
+}
+
+func RemoveEmptyDirectories(dir string) (empty bool) {
+
+	// Remove directories left empty by a move, deepest first
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	empty = true
+	for _, entry := range entries {
+		if !entry.IsDir() || !RemoveEmptyDirectories(filepath.Join(dir, entry.Name())) {
+			empty = false
+		}
+	}
+	if empty {
+		log.Printf("Removing empty directory %s\n", dir)
+		empty = os.Remove(dir) == nil
+	}
+	return
+}
+
+func GetPaneTransferSize(transfers []PaneTransfer) (size int64) {
+	for _, transfer := range transfers {
+		size += transfer.Size
+	}
+	return
+}
@@ -0,0 +1,408 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gizak/termui"
+)
+
+type Commander struct {
+	panes   [2]*Pane
+	focus   int
+	buckets []BucketWithDisplay // offered when a pane switches bucket
+	back    func()
+}
+
+func (c *Commander) Close() {
+	for _, pane := range c.panes {
+		pane.Close()
+	}
+}
+
+func OpenCommander(buckets []BucketWithDisplay, bucket BucketWithDisplay, back func()) {
+
+	// Start with the bucket on the left and the working directory on the right
+
+	termui.Render(RenderMessage("Loading Bucket", bucket.displayString))
+	left, err := NewBucketPane(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	right, err := NewLocalPane(downloadDir)
+	if err != nil {
+		left.Close()
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	RenderCommander(&Commander{panes: [2]*Pane{left, right}, buckets: buckets, back: back})
+}
+
+func CreatePaneList(pane *Pane, x int, width int, focused bool) *termui.List {
+
+	// A half width directory listing with sizes aligned to the right
+
+	nodes := GetNodeDirectory(pane.Dir)
+	nameWidth := width - 24
+	if nameWidth < 8 {
+		nameWidth = 8
+	}
+	var displayStrings []string
+	for _, node := range nodes {
+		name := node.DisplayString
+		if pane.Marked[node] {
+			name = "* " + name
+		}
+		if len(name) > nameWidth {
+			name = name[:nameWidth-3] + "..."
+		}
+		size := ""
+		if !node.Info.IsDir && node.S3Object != nil {
+			size = ByteFormat(float64(aws.Int64Value(node.S3Object.Size)), 1)
+		} else if !node.Info.IsDir {
+			size = ByteFormat(float64(node.Info.Size), 1)
+		}
+		displayStrings = append(displayStrings, fmt.Sprintf("%-*s %10s", nameWidth, name, size))
+	}
+	listing, err := GetDirectoryDisplayListing(displayStrings, pane.Selection)
+	if err != nil {
+		RenderError(err.Error())
+		return &termui.List{}
+	}
+
+	title := pane.Location()
+	if len(pane.Marked) > 0 {
+		title = fmt.Sprintf("%s [%d marked]", title, len(pane.Marked))
+	}
+	if len(title) > width-4 {
+		title = "..." + title[len(title)-(width-7):]
+	}
+	ls := termui.NewList()
+	ls.Items = listing
+	ls.ItemFgColor = termui.ColorYellow
+	ls.BorderLabel = title
+	ls.BorderFg = termui.ColorWhite
+	if focused {
+		ls.BorderFg = termui.ColorCyan
+	}
+	ls.Height = termui.TermHeight() - LOWER_BUFFER
+	ls.Width = width
+	ls.X = x
+	ls.Y = 0
+	return ls
+}
+
+func RenderCommanderHelp() *termui.Par {
+	return RenderHelp("<tab> switch pane", "<space> mark", "<c> copy", "<m> move", "<l> local dir", "<B> bucket", "<r> refresh",
+		"<n> new dir", "<R> rename", "<x> delete", "<t> download here")
+}
+
+func DrawCommander(c *Commander) {
+	width := termui.TermWidth() / 2
+	termui.Render(
+		CreatePaneList(c.panes[0], 0, width, c.focus == 0),
+		CreatePaneList(c.panes[1], width, width, c.focus == 1),
+		RenderCommanderHelp(),
+	)
+}
+
+func RenderCommander(c *Commander) {
+
+	termui.Clear()
+	DrawCommander(c)
+	termui.ResetHandlers()
+	SetDefaultHandlers(c.Close)
+
+	current := func() {
+		RenderCommander(c)
+	}
+
+	// Back leaves the commander
+
+	SetBackHandler(func() {
+		c.Close()
+		c.back()
+	})
+
+	// tab moves the focus to the other pane
+
+	termui.Handle("/sys/kbd/<tab>", func(termui.Event) {
+		c.focus = 1 - c.focus
+		DrawCommander(c)
+	})
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		pane := c.panes[c.focus]
+		if pane.Selection > 0 {
+			pane.Selection -= 1
+			DrawCommander(c)
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		pane := c.panes[c.focus]
+		if pane.Selection < len(GetNodeDirectory(pane.Dir))-1 {
+			pane.Selection += 1
+			DrawCommander(c)
+		}
+	})
+
+	// Enter changes directory, backspace goes up one
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		pane := c.panes[c.focus]
+		nodes := GetNodeDirectory(pane.Dir)
+		if len(nodes) == 0 || !nodes[pane.Selection].Info.IsDir {
+			return
+		}
+		if err := pane.Open(nodes[pane.Selection]); err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+		}
+		current()
+	})
+
+	goUp := func(termui.Event) {
+		pane := c.panes[c.focus]
+		if pane.Dir.Parent == nil {
+			return
+		}
+		if err := pane.Open(&Node{FullPath: pane.Dir.Parent.FullPath, DisplayString: ".."}); err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+		}
+		current()
+	}
+	termui.Handle("/sys/kbd/<backspace>", goUp)
+	termui.Handle("/sys/kbd/C-8", goUp)
+
+	// space marks or unmarks the selection
+
+	termui.Handle("/sys/kbd/<space>", func(termui.Event) {
+		pane := c.panes[c.focus]
+		nodes := GetNodeDirectory(pane.Dir)
+		if len(nodes) == 0 || nodes[pane.Selection].DisplayString == ".." {
+			return
+		}
+		node := nodes[pane.Selection]
+		if pane.Marked[node] {
+			delete(pane.Marked, node)
+		} else {
+			pane.Marked[node] = true
+		}
+		if pane.Selection < len(nodes)-1 {
+			pane.Selection += 1
+		}
+		DrawCommander(c)
+	})
+
+	// c copies and m moves to the other pane (F5 and F6 as in other
+	// commanders)
+
+	copyHandler := func(termui.Event) { RenderPaneTransfer(c, false) }
+	moveHandler := func(termui.Event) { RenderPaneTransfer(c, true) }
+	termui.Handle("/sys/kbd/c", copyHandler)
+	termui.Handle("/sys/kbd/<f5>", copyHandler)
+	termui.Handle("/sys/kbd/m", moveHandler)
+	termui.Handle("/sys/kbd/<f6>", moveHandler)
+
+	// l shows a local directory in the focused pane
+
+	termui.Handle("/sys/kbd/l", func(termui.Event) {
+		RenderInputPrompt("Local directory", downloadDir, func(dir string) {
+			pane, err := NewLocalPane(dir)
+			if err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				current()
+				return
+			}
+			c.panes[c.focus].Close()
+			c.panes[c.focus] = pane
+			current()
+		}, current)
+	})
+
+	// B shows a bucket in the focused pane
+
+	termui.Handle("/sys/kbd/B", func(termui.Event) {
+		var names []string
+		for _, bucket := range c.buckets {
+			names = append(names, bucket.displayString)
+		}
+		RenderChoicePrompt("Bucket", names, func(choice int) {
+			termui.Render(RenderMessage("Loading Bucket", c.buckets[choice].displayString))
+			pane, err := NewBucketPane(c.buckets[choice])
+			if err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				current()
+				return
+			}
+			c.panes[c.focus].Close()
+			c.panes[c.focus] = pane
+			current()
+		}, current)
+	})
+
+	// n, R, x and t manage files in a local pane
+
+	localPane := func() *Pane {
+		pane := c.panes[c.focus]
+		if !pane.Local {
+			RenderError("Only available in a local pane")
+			current()
+			return nil
+		}
+		return pane
+	}
+	reload := func() {
+		ReloadPanes(c.panes[c.focus])
+		current()
+	}
+
+	termui.Handle("/sys/kbd/n", func(termui.Event) {
+		if pane := localPane(); pane != nil {
+			PromptLocalMkdir(pane.Dir.FullPath, reload, current)
+		}
+	})
+
+	termui.Handle("/sys/kbd/R", func(termui.Event) {
+		pane := localPane()
+		if pane == nil {
+			return
+		}
+		nodes := GetNodeDirectory(pane.Dir)
+		if len(nodes) == 0 || nodes[pane.Selection].DisplayString == ".." {
+			return
+		}
+		PromptLocalRename(nodes[pane.Selection], reload, current)
+	})
+
+	termui.Handle("/sys/kbd/x", func(termui.Event) {
+		pane := localPane()
+		if pane == nil {
+			return
+		}
+		targets := pane.Targets()
+		if len(targets) == 0 {
+			return
+		}
+		ConfirmLocalDelete(targets, reload, current)
+	})
+
+	termui.Handle("/sys/kbd/t", func(termui.Event) {
+		if pane := localPane(); pane != nil {
+			SetDownloadTarget(pane.Dir.FullPath)
+			termui.Render(CreateStatusPrompt(fmt.Sprintf("Downloads now go to %s", pane.Dir.FullPath)))
+		}
+	})
+
+	// r reloads both panes
+
+	termui.Handle("/sys/kbd/r", func(termui.Event) {
+		termui.Render(CreateStatusPrompt("Refreshing"))
+		ReloadPanes(c.panes[0], c.panes[1])
+		current()
+	})
+}
+
+func ReloadPanes(panes ...*Pane) {
+	for _, pane := range panes {
+		if err := pane.Reload(); err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+		}
+		if count := len(GetNodeDirectory(pane.Dir)); pane.Selection >= count {
+			pane.Selection = count - 1
+		}
+		if pane.Selection < 0 {
+			pane.Selection = 0
+		}
+	}
+}
+
+func RenderPaneTransfer(c *Commander, move bool) {
+
+	// Confirm and run a copy or move from the focused pane to the other one
+
+	current := func() {
+		RenderCommander(c)
+	}
+	src, dest := c.panes[c.focus], c.panes[1-c.focus]
+	targets := src.Targets()
+	if len(targets) == 0 {
+		return
+	}
+	transfers, err := GetPaneTransfers(src, dest, targets)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+	if len(transfers) == 0 {
+		RenderError("Nothing to transfer")
+		return
+	}
+	verb, progress := "Copy", "Copying files"
+	if move {
+		verb, progress = "Move", "Moving files"
+	}
+	message := fmt.Sprintf("%s %d files (%s, %s) to %s? Existing files are overwritten.",
+		verb, len(transfers), ByteFormat(float64(GetPaneTransferSize(transfers)), 1), GetPaneTransferAction(src, dest), dest.Location())
+	RenderConfirmPrompt(verb, message, func() {
+		termui.Clear()
+		var failures []string
+		for i, transfer := range transfers {
+			termui.Render(CreateProgressGauge(progress, i, len(transfers)))
+			err := RunPaneTransfer(src, dest, transfer)
+			if err == nil && move {
+				err = RemovePaneSource(src, transfer)
+			}
+			if err != nil {
+				log.Println(err)
+				failures = append(failures, fmt.Sprintf("%s: %s", transfer.Source, err))
+			}
+		}
+
+		// Moved local directories leave empty directories behind
+
+		if move && src.Local {
+			for _, target := range targets {
+				if target.Info.IsDir {
+					RemoveEmptyDirectories(target.FullPath)
+				}
+			}
+		}
+		termui.Render(CreateStatusPrompt("Refreshing"))
+		ReloadPanes(src, dest)
+		if len(failures) > 0 {
+			RenderTextViewer(fmt.Sprintf("%s Failures", verb), failures, current)
+			return
+		}
+		current()
+	}, current)
+}
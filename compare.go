@@ -0,0 +1,296 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// One side of a comparison: a prefix in a bucket, reached with its own
+// session (and so its own region and credentials)
+
+type CompareLocation struct {
+	Session S3Session
+	Bucket  BucketWithDisplay
+	Prefix  string
+	Profile string // "" for the default credentials
+}
+
+type ObjectComparison struct {
+	RelPath string
+	Status  string   // one of the COMPARE_* constants
+	Reasons []string // what differs on mismatched objects
+	Source  *s3.Object
+	Dest    *s3.Object
+}
+
+func (l CompareLocation) String() string {
+	return FormatS3Url(*l.Bucket.bucket.Name, l.Prefix)
+}
+
+func ResolveCompareLocation(url string, profile string) (location CompareLocation, err error) {
+
+	// Open a session for one side of a comparison
+
+	name, key, err := ParseS3Url(url)
+	if err != nil {
+		return
+	}
+	sess, bucket, err := ResolveProfileBucket(name, profile)
+	if err != nil {
+		return
+	}
+	location = CompareLocation{Session: sess, Bucket: bucket, Prefix: GetSyncPrefix(key), Profile: profile}
+	return
+}
+
+func CompareObjectLists(srcPrefix string, src []*s3.Object, destPrefix string, dest []*s3.Object) (comparisons []ObjectComparison) {
+
+	// Match keys by their path below each prefix and compare sizes and
+	// ETags. Multipart ETags depend on the part size, so a mismatch between
+	// them is flagged as such.
+
+	remote := make(map[string]*s3.Object)
+	for _, object := range dest {
+		if !strings.HasSuffix(*object.Key, "/") {
+			remote[strings.TrimPrefix(*object.Key, destPrefix)] = object
+		}
+	}
+	seen := make(map[string]bool)
+	for _, object := range src {
+		if strings.HasSuffix(*object.Key, "/") {
+			continue
+		}
+		rel := strings.TrimPrefix(*object.Key, srcPrefix)
+		seen[rel] = true
+		comparison := ObjectComparison{RelPath: rel, Status: COMPARE_MATCHED, Source: object, Dest: remote[rel]}
+		if comparison.Dest == nil {
+			comparison.Status = COMPARE_MISSING
+			comparisons = append(comparisons, comparison)
+			continue
+		}
+		if aws.Int64Value(object.Size) != aws.Int64Value(comparison.Dest.Size) {
+			comparison.Reasons = append(comparison.Reasons, "size")
+		}
+		srcETag, destETag := GetObjectETag(object), GetObjectETag(comparison.Dest)
+		if srcETag != destETag {
+			if IsMultipartETag(srcETag) || IsMultipartETag(destETag) {
+				comparison.Reasons = append(comparison.Reasons, "etag (multipart)")
+			} else {
+				comparison.Reasons = append(comparison.Reasons, "etag")
+			}
+		}
+		if len(comparison.Reasons) > 0 {
+			comparison.Status = COMPARE_MISMATCHED
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	for rel, object := range remote {
+		if !seen[rel] {
+			comparisons = append(comparisons, ObjectComparison{RelPath: rel, Status: COMPARE_EXTRA, Dest: object})
+		}
+	}
+	SortComparisons(comparisons)
+	return
+}
+
+func SortComparisons(comparisons []ObjectComparison) {
+	sort.Slice(comparisons, func(i, j int) bool {
+		return comparisons[i].RelPath < comparisons[j].RelPath
+	})
+}
+
+func GetMetadataDifferences(src *s3.HeadObjectOutput, dest *s3.HeadObjectOutput) (reasons []string) {
+
+	// The headers that travel with an object, and its user metadata
+
+	headers := [][3]string{
+		{"content-type", aws.StringValue(src.ContentType), aws.StringValue(dest.ContentType)},
+		{"cache-control", aws.StringValue(src.CacheControl), aws.StringValue(dest.CacheControl)},
+		{"content-encoding", aws.StringValue(src.ContentEncoding), aws.StringValue(dest.ContentEncoding)},
+		{"content-disposition", aws.StringValue(src.ContentDisposition), aws.StringValue(dest.ContentDisposition)},
+		{"content-language", aws.StringValue(src.ContentLanguage), aws.StringValue(dest.ContentLanguage)},
+	}
+	for _, header := range headers {
+		if header[1] != header[2] {
+			reasons = append(reasons, header[0])
+		}
+	}
+	srcMeta, destMeta := aws.StringValueMap(src.Metadata), aws.StringValueMap(dest.Metadata)
+	var names []string
+	for name, value := range srcMeta {
+		if other, ok := destMeta[name]; !ok || other != value {
+			names = append(names, name)
+		}
+	}
+	for name := range destMeta {
+		if _, ok := srcMeta[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		reasons = append(reasons, "metadata "+strings.ToLower(name))
+	}
+	return
+}
+
+func CompareObjectMetadata(src CompareLocation, dest CompareLocation, comparisons []ObjectComparison, progress func(done int, total int)) (failures []string) {
+
+	// Head both copies of every key present on both sides and add any
+	// metadata differences to the reasons
+
+	var indexes []int
+	for i, comparison := range comparisons {
+		if comparison.Source != nil && comparison.Dest != nil {
+			indexes = append(indexes, i)
+		}
+	}
+	log.Printf("Comparing metadata of %d objects\n", len(indexes))
+	jobs := make(chan int)
+	var lock sync.Mutex
+	var workers sync.WaitGroup
+	done := 0
+	for w := 0; w < DEFAULT_COMPARE_PARALLEL; w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range jobs {
+				comparison := &comparisons[i]
+				srcHead, err := src.Session.HeadObject(src.Bucket, *comparison.Source.Key)
+				var destHead *s3.HeadObjectOutput
+				if err == nil {
+					destHead, err = dest.Session.HeadObject(dest.Bucket, *comparison.Dest.Key)
+				}
+				lock.Lock()
+				done += 1
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("%s: %s", comparison.RelPath, err))
+				} else if reasons := GetMetadataDifferences(srcHead, destHead); len(reasons) > 0 {
+					comparison.Reasons = append(comparison.Reasons, reasons...)
+					comparison.Status = COMPARE_MISMATCHED
+				}
+				if progress != nil {
+					progress(done, len(indexes))
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, i := range indexes {
+		jobs <- i
+	}
+	close(jobs)
+	workers.Wait()
+	return
+}
+
+func CompareLocations(src CompareLocation, dest CompareLocation, metadata bool, progress func(done int, total int)) (comparisons []ObjectComparison, failures []string, err error) {
+
+	// List both sides and compare them
+
+	log.Printf("Comparing %s with %s\n", src, dest)
+	srcObjects, _, err := src.Session.ListPrefix(src.Bucket, src.Prefix, true)
+	if err != nil {
+		return
+	}
+	destObjects, _, err := dest.Session.ListPrefix(dest.Bucket, dest.Prefix, true)
+	if err != nil {
+		return
+	}
+	comparisons = CompareObjectLists(src.Prefix, srcObjects, dest.Prefix, destObjects)
+	if metadata {
+		failures = CompareObjectMetadata(src, dest, comparisons, progress)
+	}
+	return
+}
+
+func CopyComparedObject(from CompareLocation, to CompareLocation, rel string, size int64) error {
+
+	// Server side copy between the two sides, made with the destination's
+	// session, whose credentials need read access to the source
+
+	if size > MAX_COPY_OBJECT_SIZE {
+		return fmt.Errorf("%s is too large for a server side copy", rel)
+	}
+	return to.Session.CopyObject(from.Bucket, from.Prefix+rel, to.Bucket, to.Prefix+rel)
+}
+
+func CountComparisons(comparisons []ObjectComparison) map[string]int {
+	counts := make(map[string]int)
+	for _, comparison := range comparisons {
+		counts[comparison.Status] += 1
+	}
+	return counts
+}
+
+func FormatComparisonCounts(counts map[string]int) string {
+	return fmt.Sprintf("%d missing, %d extra, %d mismatched, %d matched",
+		counts[COMPARE_MISSING], counts[COMPARE_EXTRA], counts[COMPARE_MISMATCHED], counts[COMPARE_MATCHED])
+}
+
+func FormatObjectComparison(comparison ObjectComparison) string {
+	srcSize, destSize := "-", "-"
+	if comparison.Source != nil {
+		srcSize = ByteFormat(float64(aws.Int64Value(comparison.Source.Size)), 1)
+	}
+	if comparison.Dest != nil {
+		destSize = ByteFormat(float64(aws.Int64Value(comparison.Dest.Size)), 1)
+	}
+	line := fmt.Sprintf("%-12s %10s %10s  %s", comparison.Status, srcSize, destSize, comparison.RelPath)
+	if len(comparison.Reasons) > 0 {
+		line += fmt.Sprintf(" (%s)", strings.Join(comparison.Reasons, ", "))
+	}
+	return line
+}
+
+func GetComparisonDetailLines(src CompareLocation, dest CompareLocation, comparison ObjectComparison) (lines []string) {
+
+	// Both copies of one key side by side
+
+	lines = append(lines, fmt.Sprintf("Status:  %s", comparison.Status))
+	if len(comparison.Reasons) > 0 {
+		lines = append(lines, fmt.Sprintf("Differs: %s", strings.Join(comparison.Reasons, ", ")))
+	}
+	sides := []struct {
+		label    string
+		location CompareLocation
+		object   *s3.Object
+	}{{"Source", src, comparison.Source}, {"Destination", dest, comparison.Dest}}
+	for _, side := range sides {
+		lines = append(lines, "", fmt.Sprintf("%s: %s", side.label, FormatS3Url(*side.location.Bucket.bucket.Name, side.location.Prefix+comparison.RelPath)))
+		if side.object == nil {
+			lines = append(lines, "  (missing)")
+			continue
+		}
+		lines = append(lines,
+			fmt.Sprintf("  Size:          %d", aws.Int64Value(side.object.Size)),
+			fmt.Sprintf("  ETag:          %s", GetObjectETag(side.object)),
+			fmt.Sprintf("  Last Modified: %s", FormatRecordTime(aws.TimeValue(side.object.LastModified))),
+			fmt.Sprintf("  Storage Class: %s", aws.StringValue(side.object.StorageClass)),
+		)
+	}
+	return
+}
@@ -0,0 +1,76 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestCompareObjectLists(t *testing.T) {
+	object := func(key string, size int64, etag string) *s3.Object {
+		return &s3.Object{Key: aws.String(key), Size: aws.Int64(size), ETag: aws.String("\"" + etag + "\"")}
+	}
+	src := []*s3.Object{
+		object("data/same.txt", 1, "aa"),
+		object("data/missing.txt", 1, "bb"),
+		object("data/size.txt", 1, "cc"),
+		object("data/etag.txt", 1, "dd"),
+		object("data/multipart.bin", 1, "ee-2"),
+		object("data/folder/", 0, "ff"),
+	}
+	dest := []*s3.Object{
+		object("copy/same.txt", 1, "aa"),
+		object("copy/size.txt", 2, "cc"),
+		object("copy/etag.txt", 1, "00"),
+		object("copy/multipart.bin", 1, "ee-3"),
+		object("copy/extra.txt", 1, "gg"),
+	}
+	want := map[string]struct {
+		status  string
+		reasons []string
+	}{
+		"same.txt":      {COMPARE_MATCHED, nil},
+		"missing.txt":   {COMPARE_MISSING, nil},
+		"size.txt":      {COMPARE_MISMATCHED, []string{"size"}},
+		"etag.txt":      {COMPARE_MISMATCHED, []string{"etag"}},
+		"multipart.bin": {COMPARE_MISMATCHED, []string{"etag (multipart)"}},
+		"extra.txt":     {COMPARE_EXTRA, nil},
+	}
+	comparisons := CompareObjectLists("data/", src, "copy/", dest)
+	if len(comparisons) != len(want) {
+		t.Fatalf("got %d comparisons, want %d: %+v", len(comparisons), len(want), comparisons)
+	}
+	for _, comparison := range comparisons {
+		expected, ok := want[comparison.RelPath]
+		if !ok {
+			t.Errorf("unexpected comparison for %s", comparison.RelPath)
+			continue
+		}
+		if comparison.Status != expected.status || !reflect.DeepEqual(comparison.Reasons, expected.reasons) {
+			t.Errorf("%s: got %s %v, want %s %v", comparison.RelPath, comparison.Status, comparison.Reasons, expected.status, expected.reasons)
+		}
+		if strings.HasSuffix(comparison.RelPath, "/") {
+			t.Errorf("folder placeholder %s should be skipped", comparison.RelPath)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gizak/termui"
+)
+
+type PrefixCompare struct {
+	explorer    *BucketExplorer
+	src         CompareLocation
+	dest        CompareLocation
+	comparisons []ObjectComparison // differences only
+	matched     int
+	modified    bool // something was copied into the explorer's bucket
+}
+
+func RenderCompare(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Ask for the other location, its credentials and how closely to compare
+
+	bucket := explorer.bucket
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	src := CompareLocation{Session: sess, Bucket: bucket, Prefix: GetNodePrefix(dir)}
+	RenderInputPrompt(fmt.Sprintf("Compare %s with", src), src.String(), func(url string) {
+		if !IsS3Url(url) {
+			RenderError(fmt.Sprintf("Not an S3 url: %s", url))
+			back()
+			return
+		}
+		RenderInputPrompt("Credentials profile for it (blank for the default)", "", func(profile string) {
+			modes := []string{"Size and ETag", "Size, ETag and metadata (one request per object on each side)"}
+			RenderChoicePrompt("Compare by", modes, func(choice int) {
+				RunCompare(explorer, src, url, strings.TrimSpace(profile), choice == 1, back)
+			}, back)
+		}, back)
+	}, back)
+}
+
+func RunCompare(explorer *BucketExplorer, src CompareLocation, url string, profile string, metadata bool, back func()) {
+
+	// Compare with progress and show the differences
+
+	termui.Clear()
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Comparing %s with %s", src, url)))
+	dest, err := ResolveCompareLocation(url, profile)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	comparisons, failures, err := CompareLocations(src, dest, metadata, func(done int, total int) {
+		termui.Render(CreateProgressGauge("Comparing metadata", done, total))
+	})
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	state := &PrefixCompare{explorer: explorer, src: src, dest: dest}
+	for _, comparison := range comparisons {
+		if comparison.Status == COMPARE_MATCHED {
+			state.matched += 1
+		} else {
+			state.comparisons = append(state.comparisons, comparison)
+		}
+	}
+	if len(failures) > 0 {
+		RenderTextViewer("Metadata Failures", failures, func() {
+			RenderCompareListing(state, 0, back)
+		})
+		return
+	}
+	RenderCompareListing(state, 0, back)
+}
+
+func GetCompareTitle(state *PrefixCompare) string {
+	counts := CountComparisons(state.comparisons)
+	counts[COMPARE_MATCHED] = state.matched
+	return fmt.Sprintf("%s vs %s: %s", state.src, state.dest, FormatComparisonCounts(counts))
+}
+
+func RenderCompareHelp() *termui.Par {
+	return RenderHelp("<enter> details", "<c> copy", "<m> copy all missing")
+}
+
+func RenderCompareListing(state *PrefixCompare, selection int, back func()) {
+
+	if selection >= len(state.comparisons) {
+		selection = len(state.comparisons) - 1
+	}
+	if selection < 0 {
+		selection = 0
+	}
+	title := GetCompareTitle(state)
+	var lines []string
+	for _, comparison := range state.comparisons {
+		lines = append(lines, FormatObjectComparison(comparison))
+	}
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderCompareHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(state.explorer.deferFunc)
+
+	current := func() {
+		RenderCompareListing(state, selection, back)
+	}
+
+	// Copies into this bucket make the explorer's tree stale
+
+	SetBackHandler(func() {
+		if state.modified {
+			state.explorer.deferFunc()
+			LoadBucketExplorer(state.explorer.bucket, state.explorer.showDeleted)
+			return
+		}
+		back()
+	})
+
+	if len(state.comparisons) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderCompareHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(state.comparisons)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderCompareHelp())
+		}
+	})
+
+	// Enter shows both copies side by side
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		comparison := state.comparisons[selection]
+		RenderTextViewer(comparison.RelPath, GetComparisonDetailLines(state.src, state.dest, comparison), current)
+	})
+
+	// c copies the selected object to the side it is missing from, asking
+	// which way for mismatched objects
+
+	termui.Handle("/sys/kbd/c", func(termui.Event) {
+		comparison := state.comparisons[selection]
+		switch comparison.Status {
+		case COMPARE_MISSING:
+			CopyComparison(state, selection, true)
+			current()
+		case COMPARE_EXTRA:
+			CopyComparison(state, selection, false)
+			current()
+		default:
+			directions := []string{
+				fmt.Sprintf("Source to destination (overwrite %s)", state.dest),
+				fmt.Sprintf("Destination to source (overwrite %s)", state.src),
+			}
+			RenderChoicePrompt(fmt.Sprintf("Copy %s", comparison.RelPath), directions, func(choice int) {
+				CopyComparison(state, selection, choice == 0)
+				current()
+			}, current)
+		}
+	})
+
+	// m copies everything missing from the destination
+
+	termui.Handle("/sys/kbd/m", func(termui.Event) {
+		missing := CountComparisons(state.comparisons)[COMPARE_MISSING]
+		if missing == 0 {
+			return
+		}
+		RenderConfirmPrompt("Copy Missing", fmt.Sprintf("Copy %d missing objects to %s?", missing, state.dest), func() {
+			CopyMissingComparisons(state, back)
+		}, current)
+	})
+}
+
+func CopyComparison(state *PrefixCompare, index int, toDest bool) {
+
+	// Copy one object across and count it as matched
+
+	comparison := state.comparisons[index]
+	from, to := state.src, state.dest
+	size := aws.Int64Value(comparison.Source.Size)
+	if !toDest {
+		from, to = state.dest, state.src
+		size = aws.Int64Value(comparison.Dest.Size)
+	}
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Copying %s", comparison.RelPath)))
+	if err := CopyComparedObject(from, to, comparison.RelPath, size); err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+	state.modified = state.modified || IsExplorerBucket(state, to)
+	state.comparisons = append(state.comparisons[:index], state.comparisons[index+1:]...)
+	state.matched += 1
+}
+
+func CopyMissingComparisons(state *PrefixCompare, back func()) {
+
+	// Copy every missing object with a progress bar, listing any failures
+
+	termui.Clear()
+	var remaining []ObjectComparison
+	var failures []string
+	var missing []ObjectComparison
+	for _, comparison := range state.comparisons {
+		if comparison.Status == COMPARE_MISSING {
+			missing = append(missing, comparison)
+		} else {
+			remaining = append(remaining, comparison)
+		}
+	}
+	for i, comparison := range missing {
+		termui.Render(CreateProgressGauge("Copying missing objects", i, len(missing)))
+		err := CopyComparedObject(state.src, state.dest, comparison.RelPath, aws.Int64Value(comparison.Source.Size))
+		if err != nil {
+			log.Println(err)
+			failures = append(failures, fmt.Sprintf("%s: %s", comparison.RelPath, err))
+			remaining = append(remaining, comparison)
+			continue
+		}
+		state.matched += 1
+		state.modified = state.modified || IsExplorerBucket(state, state.dest)
+	}
+	state.comparisons = remaining
+	SortComparisons(state.comparisons)
+	done := func() {
+		RenderCompareListing(state, 0, back)
+	}
+	if len(failures) > 0 {
+		RenderTextViewer("Copy Failures", failures, done)
+		return
+	}
+	done()
+}
+
+func IsExplorerBucket(state *PrefixCompare, location CompareLocation) bool {
+	return *location.Bucket.bucket.Name == *state.explorer.bucket.bucket.Name
+}
@@ -0,0 +1,115 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+type Config struct {
+	Openers        []Opener         `json:"openers"`
+	ShareLog       string           `json:"share_log"`
+	BackupDir      string           `json:"backup_dir"`
+	Prices         PriceTable       `json:"prices"`
+	Providers      []ProviderConfig `json:"providers"`
+	RegionCache    string           `json:"region_cache"`
+	RegionCacheTTL string           `json:"region_cache_ttl"`
+}
+
+func HomePath(name string) string {
+
+	// Files we keep live in the user's home directory if we can find it.
+	// This can run before the logger is set up, so stay quiet.
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return name
+	}
+	return filepath.Join(home, name)
+}
+
+func DefaultConfigPath() string {
+	return HomePath(DEFAULT_CONFIG_FILE)
+}
+
+func DefaultConfig() Config {
+
+	// Defaults used when no config file exists
+
+	return Config{
+		Openers:        DefaultOpeners(),
+		ShareLog:       HomePath(DEFAULT_SHARE_LOG_FILE),
+		BackupDir:      HomePath(DEFAULT_BACKUP_DIR),
+		Prices:         DefaultPriceTable(),
+		RegionCache:    HomePath(DEFAULT_REGION_CACHE_FILE),
+		RegionCacheTTL: DEFAULT_REGION_CACHE_TTL,
+	}
+}
+
+func LoadConfig(path string) (config Config, err error) {
+
+	// A missing config file is not an error, just use the defaults
+
+	config = DefaultConfig()
+	if !FileExists(path) {
+		log.Printf("No config file at %s, using defaults\n", path)
+		return
+	}
+
+	log.Printf("Loading config file: %s\n", path)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	// Only override the defaults for sections present in the file
+
+	var fileConfig Config
+	err = json.Unmarshal(data, &fileConfig)
+	if err != nil {
+		return
+	}
+	if len(fileConfig.Openers) > 0 {
+		config.Openers = fileConfig.Openers
+	}
+	if fileConfig.ShareLog != "" {
+		config.ShareLog = fileConfig.ShareLog
+	}
+	if fileConfig.BackupDir != "" {
+		config.BackupDir = fileConfig.BackupDir
+	}
+	config.Prices = MergePriceTables(config.Prices, fileConfig.Prices)
+	config.Providers = fileConfig.Providers
+	if fileConfig.RegionCache != "" {
+		config.RegionCache = fileConfig.RegionCache
+	}
+	if fileConfig.RegionCacheTTL != "" {
+		config.RegionCacheTTL = fileConfig.RegionCacheTTL
+	}
+
+	// Providers hold credentials, so only log them redacted
+
+	logged := config
+	logged.Providers = RedactProviders(config.Providers)
+	log.Printf("Loaded config: %+v\n", logged)
+	return
+}
@@ -0,0 +1,197 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type ClassCost struct {
+	Bytes         int64   // stored bytes
+	BillableBytes int64   // after minimum sizes and per-object overhead
+	Count         int     // objects
+	BelowMinimum  int     // objects billed at more than their size
+	Cost          float64 // per month
+}
+
+type CostEstimate struct {
+	Name    string
+	Bytes   int64
+	Count   int
+	Cost    float64
+	Classes map[string]*ClassCost
+}
+
+func NewCostEstimate(name string) *CostEstimate {
+	return &CostEstimate{Name: name, Classes: make(map[string]*ClassCost)}
+}
+
+func (c *CostEstimate) Add(region string, object *s3.Object) {
+
+	// Add one object's estimated monthly cost
+
+	class := GetStorageClassDisplay(object)
+	size := aws.Int64Value(object.Size)
+	classBytes, standardBytes := GetBillableBytes(class, size)
+	cost := EstimateObjectStorageCost(region, class, size)
+
+	classCost, ok := c.Classes[class]
+	if !ok {
+		classCost = &ClassCost{}
+		c.Classes[class] = classCost
+	}
+	classCost.Bytes += size
+	classCost.BillableBytes += classBytes + standardBytes
+	classCost.Count += 1
+	classCost.Cost += cost
+	if classBytes+standardBytes > size {
+		classCost.BelowMinimum += 1
+	}
+	c.Bytes += size
+	c.Count += 1
+	c.Cost += cost
+}
+
+func (c *CostEstimate) GetClasses() (classes []string) {
+
+	// Storage classes in this estimate, most expensive first
+
+	for class := range c.Classes {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return c.Classes[classes[i]].Cost > c.Classes[classes[j]].Cost
+	})
+	return
+}
+
+func FormatCost(cost float64) string {
+	return fmt.Sprintf("%.2f %s", cost, config.Prices.Currency)
+}
+
+func GetNextPrefix(key string, prefix string) string {
+
+	// The first path segment of a key below prefix, with its trailing "/"
+
+	rel := strings.TrimPrefix(key, prefix)
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return prefix + rel[:i+1]
+	}
+	return ROOT_PREFIX_DISPLAY
+}
+
+func EstimateCosts(region string, prefix string, objects []*s3.Object) (total *CostEstimate, prefixes []*CostEstimate) {
+
+	// Total the monthly cost of every object, and split it by the next level
+	// of prefixes below prefix
+
+	total = NewCostEstimate(prefix)
+	byPrefix := make(map[string]*CostEstimate)
+	for _, object := range objects {
+		total.Add(region, object)
+		name := GetNextPrefix(*object.Key, prefix)
+		estimate, ok := byPrefix[name]
+		if !ok {
+			estimate = NewCostEstimate(name)
+			byPrefix[name] = estimate
+			prefixes = append(prefixes, estimate)
+		}
+		estimate.Add(region, object)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return prefixes[i].Cost > prefixes[j].Cost
+	})
+	return
+}
+
+func GetCostCaveats(total *CostEstimate) (lines []string) {
+
+	// Billing rules that apply to the classes in use
+
+	for _, class := range total.GetClasses() {
+		minimums, ok := storageClassMinimums[class]
+		if !ok {
+			continue
+		}
+		classCost := total.Classes[class]
+		line := fmt.Sprintf("%s: billed for at least %d days after each upload or transition", class, minimums.Days)
+		switch {
+		case minimums.MinimumSize > 0:
+			line += fmt.Sprintf(", objects under %s are billed as %s (%d objects here)",
+				ByteFormat(float64(minimums.MinimumSize), 0), ByteFormat(float64(minimums.MinimumSize), 0), classCost.BelowMinimum)
+		case minimums.Overhead > 0:
+			line += fmt.Sprintf(", plus %s of index data per object (%d objects here)",
+				ByteFormat(float64(minimums.Overhead+minimums.StandardOverhead), 0), classCost.Count)
+		}
+		lines = append(lines, "  "+line)
+	}
+	if _, ok := total.Classes[s3.StorageClassIntelligentTiering]; ok {
+		lines = append(lines, "  "+s3.StorageClassIntelligentTiering+": priced at the frequent access tier, monitoring fees are not included")
+	}
+	return
+}
+
+func GetCostReportLines(title string, region string, prefix string, objects []*s3.Object) (lines []string) {
+
+	// Monthly storage cost in total, by storage class and by prefix
+
+	total, prefixes := EstimateCosts(region, prefix, objects)
+	lines = append(lines,
+		fmt.Sprintf("%s (%s)", title, region),
+		fmt.Sprintf("Estimated storage: %s/month for %s in %d objects", FormatCost(total.Cost), ByteFormat(float64(total.Bytes), 1), total.Count),
+		"",
+		"By storage class:",
+		fmt.Sprintf("  %-20s %10s %12s %10s %16s", "Class", "Size", "Billable", "Objects", "Per Month"),
+	)
+	for _, class := range total.GetClasses() {
+		classCost := total.Classes[class]
+		lines = append(lines, fmt.Sprintf("  %-20s %10s %12s %10d %16s",
+			class, ByteFormat(float64(classCost.Bytes), 1), ByteFormat(float64(classCost.BillableBytes), 1),
+			classCost.Count, FormatCost(classCost.Cost)))
+	}
+
+	lines = append(lines,
+		"",
+		"By prefix:",
+		fmt.Sprintf("  %-40s %10s %10s %16s %6s", "Prefix", "Size", "Objects", "Per Month", "Share"),
+	)
+	for _, estimate := range prefixes {
+		share := 0.0
+		if total.Cost > 0 {
+			share = estimate.Cost * 100 / total.Cost
+		}
+		lines = append(lines, fmt.Sprintf("  %-40s %10s %10d %16s %5.1f%%",
+			estimate.Name, ByteFormat(float64(estimate.Bytes), 1), estimate.Count, FormatCost(estimate.Cost), share))
+	}
+
+	if caveats := GetCostCaveats(total); len(caveats) > 0 {
+		lines = append(lines, "", "Caveats:")
+		lines = append(lines, caveats...)
+	}
+	lines = append(lines,
+		"",
+		"Storage only: requests, retrievals, transfer and old versions are not included.",
+		"Prices are estimates from the price table (see the prices config).",
+	)
+	return
+}
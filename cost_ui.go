@@ -0,0 +1,65 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gizak/termui"
+)
+
+func RenderBucketCost(bucket BucketWithDisplay, back func()) {
+
+	// List the whole bucket and show its estimated monthly cost
+
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Listing %s", *bucket.bucket.Name)))
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	objects, err := sess.GetBucketObjects(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	lines := GetCostReportLines(fmt.Sprintf("s3://%s", *bucket.bucket.Name), bucket.region, "", objects)
+	RenderTextViewer("Storage Cost", lines, back)
+}
+
+func RenderDirectoryCost(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Estimate the cost of everything under a directory of the explorer,
+	// leaving out deleted objects
+
+	var objects []*s3.Object
+	for _, node := range GetFileNodes(dir) {
+		if !node.IsDeleted {
+			objects = append(objects, node.S3Object)
+		}
+	}
+	prefix := GetNodePrefix(dir)
+	title := fmt.Sprintf("s3://%s/%s", *explorer.bucket.bucket.Name, prefix)
+	RenderTextViewer("Storage Cost", GetCostReportLines(title, explorer.bucket.region, prefix, objects), back)
+}
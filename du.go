@@ -0,0 +1,144 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+type DiskUsage struct {
+	Node        *Node
+	Parent      *DiskUsage
+	Children    []*DiskUsage // largest first
+	Size        int64
+	Count       int
+	ClassSizes  map[string]int64
+	ClassCounts map[string]int
+}
+
+func GetDiskUsage(node *Node, parent *DiskUsage) *DiskUsage {
+
+	// Total up sizes and object counts under a node. Deleted objects don't
+	// show in the listing's totals.
+
+	usage := &DiskUsage{
+		Node:        node,
+		Parent:      parent,
+		ClassSizes:  make(map[string]int64),
+		ClassCounts: make(map[string]int),
+	}
+	if !node.Info.IsDir {
+		if node.S3Object != nil && !node.IsDeleted {
+			class := GetStorageClassDisplay(node.S3Object)
+			usage.Size = aws.Int64Value(node.S3Object.Size)
+			usage.Count = 1
+			usage.ClassSizes[class] = usage.Size
+			usage.ClassCounts[class] = 1
+		}
+		return usage
+	}
+	for _, child := range node.Children {
+		childUsage := GetDiskUsage(child, usage)
+		usage.Children = append(usage.Children, childUsage)
+		usage.Size += childUsage.Size
+		usage.Count += childUsage.Count
+		for class, size := range childUsage.ClassSizes {
+			usage.ClassSizes[class] += size
+			usage.ClassCounts[class] += childUsage.ClassCounts[class]
+		}
+	}
+
+	// Biggest first, then by name so equal sizes don't jump around
+
+	sort.SliceStable(usage.Children, func(i, j int) bool {
+		a, b := usage.Children[i], usage.Children[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Node.DisplayString < b.Node.DisplayString
+	})
+	return usage
+}
+
+func GetUsagePercent(part int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+func FormatUsageBar(percent float64, width int) string {
+
+	// A fixed width bar like [#####     ]
+
+	filled := int(percent*float64(width)/100 + 0.5)
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+func FormatDiskUsage(usage *DiskUsage, total int64) string {
+
+	// One listing line: size, percent of the parent, bar, count and name
+
+	// Directory names already end with the delimiter
+
+	name := usage.Node.DisplayString
+	count := ""
+	if usage.Node.Info.IsDir {
+		count = fmt.Sprintf("%d objects", usage.Count)
+	}
+	percent := GetUsagePercent(usage.Size, total)
+	return fmt.Sprintf("%10s %5.1f%% %s %14s  %s",
+		ByteFormat(float64(usage.Size), 1), percent, FormatUsageBar(percent, DU_BAR_WIDTH), count, name)
+}
+
+func GetDiskUsageTitle(bucket BucketWithDisplay, usage *DiskUsage) string {
+	return fmt.Sprintf("Disk usage: s3://%s/%s - %s in %d objects",
+		*bucket.bucket.Name, GetNodePrefix(usage.Node), ByteFormat(float64(usage.Size), 1), usage.Count)
+}
+
+func GetStorageClassBreakdown(usage *DiskUsage) (lines []string) {
+
+	// Bytes and objects per storage class, largest first
+
+	var classes []string
+	for class := range usage.ClassSizes {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		return usage.ClassSizes[classes[i]] > usage.ClassSizes[classes[j]]
+	})
+
+	lines = append(lines,
+		fmt.Sprintf("%s: %s in %d objects", usage.Node.DisplayString, ByteFormat(float64(usage.Size), 1), usage.Count),
+		"",
+	)
+	for _, class := range classes {
+		percent := GetUsagePercent(usage.ClassSizes[class], usage.Size)
+		lines = append(lines, fmt.Sprintf("%-20s %10s %5.1f%% %s %8d objects",
+			class, ByteFormat(float64(usage.ClassSizes[class]), 1), percent,
+			FormatUsageBar(percent, DU_BAR_WIDTH), usage.ClassCounts[class]))
+	}
+	return
+}
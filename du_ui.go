@@ -0,0 +1,118 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"log"
+
+	"github.com/gizak/termui"
+)
+
+func RenderDiskUsage(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Total up the directory once, then browse it
+
+	log.Printf("Calculating disk usage for %s\n", dir.FullPath)
+	usage := GetDiskUsage(dir, nil)
+	RenderDiskUsageListing(explorer, usage, 0, back)
+}
+
+func CreateDiskUsageList(bucket BucketWithDisplay, usage *DiskUsage, selection int) *termui.List {
+
+	// Create the du list for a directory
+
+	var displayStrings []string
+	for _, child := range usage.Children {
+		displayStrings = append(displayStrings, FormatDiskUsage(child, usage.Size))
+	}
+	return CreateStringList(GetDiskUsageTitle(bucket, usage), displayStrings, selection)
+}
+
+func RenderDiskUsageHelp() *termui.Par {
+	return RenderHelp("<c> storage classes")
+}
+
+func RenderDiskUsageListing(explorer *BucketExplorer, usage *DiskUsage, selection int, back func()) {
+
+	bucket := explorer.bucket
+	termui.Clear()
+	termui.Render(CreateDiskUsageList(bucket, usage, selection), RenderDiskUsageHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(explorer.deferFunc)
+
+	current := func() {
+		RenderDiskUsageListing(explorer, usage, selection, back)
+	}
+
+	// b goes up like ncdu, and back to the explorer from the top
+
+	SetBackHandler(func() {
+		if usage.Parent == nil {
+			back()
+			return
+		}
+
+		// Come back to the directory we were in
+
+		parentSelection := 0
+		for i, child := range usage.Parent.Children {
+			if child == usage {
+				parentSelection = i
+			}
+		}
+		RenderDiskUsageListing(explorer, usage.Parent, parentSelection, back)
+	})
+
+	// c breaks the selection (or this directory) down by storage class
+
+	termui.Handle("/sys/kbd/c", func(termui.Event) {
+		target := usage
+		if len(usage.Children) > 0 {
+			target = usage.Children[selection]
+		}
+		RenderTextViewer("Storage Classes", GetStorageClassBreakdown(target), current)
+	})
+
+	if len(usage.Children) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateDiskUsageList(bucket, usage, selection), RenderDiskUsageHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(usage.Children)-1 {
+			selection += 1
+			termui.Render(CreateDiskUsageList(bucket, usage, selection), RenderDiskUsageHelp())
+		}
+	})
+
+	// Enter drills down into a directory
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		child := usage.Children[selection]
+		if !child.Node.Info.IsDir {
+			return
+		}
+		RenderDiskUsageListing(explorer, child, 0, back)
+	})
+}
@@ -0,0 +1,175 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+type DuplicateGroup struct {
+	Size     int64
+	Match    string // the ETag, or content hash once verified
+	Verified bool   // matched on a full content hash
+	Nodes    []*Node
+}
+
+func (g *DuplicateGroup) Wasted() int64 {
+
+	// Every copy but one is wasted
+
+	return g.Size * int64(len(g.Nodes)-1)
+}
+
+func GetNodeETag(node *Node) string {
+	return strings.Trim(aws.StringValue(node.S3Object.ETag), "\"")
+}
+
+func IsMultipartETag(etag string) bool {
+
+	// Multipart ETags are a hash of the part hashes with a "-<parts>" suffix,
+	// so the same content uploaded with different part sizes won't match
+
+	return strings.Contains(etag, "-")
+}
+
+func GroupDuplicates(nodes []*Node, hashes map[*Node]string) (groups []*DuplicateGroup) {
+
+	// Group by size and ETag, or by size and content hash where we have one.
+	// Empty objects aren't worth reporting.
+
+	byKey := make(map[string]*DuplicateGroup)
+	for _, node := range nodes {
+		size := GetNodeSize(node)
+		if size == 0 {
+			continue
+		}
+		match, verified := GetNodeETag(node), false
+		if hash, ok := hashes[node]; ok {
+			match, verified = hash, true
+		}
+		key := fmt.Sprintf("%d/%v/%s", size, verified, match)
+		group, ok := byKey[key]
+		if !ok {
+			group = &DuplicateGroup{Size: size, Match: match, Verified: verified}
+			byKey[key] = group
+		}
+		group.Nodes = append(group.Nodes, node)
+	}
+	for _, group := range byKey {
+		if len(group.Nodes) > 1 {
+			groups = append(groups, group)
+		}
+	}
+
+	// Most wasted first, keys in order within a group
+
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Wasted() != groups[j].Wasted() {
+			return groups[i].Wasted() > groups[j].Wasted()
+		}
+		return *groups[i].Nodes[0].S3Object.Key < *groups[j].Nodes[0].S3Object.Key
+	})
+	for _, group := range groups {
+		sort.Slice(group.Nodes, func(i, j int) bool {
+			return *group.Nodes[i].S3Object.Key < *group.Nodes[j].S3Object.Key
+		})
+	}
+	return
+}
+
+func GetHashCandidates(nodes []*Node, hashes map[*Node]string) (candidates []*Node) {
+
+	// Objects worth hashing: same size as another object, where the ETags
+	// differ and at least one is multipart (so the ETags can't be compared)
+
+	bySize := make(map[int64][]*Node)
+	for _, node := range nodes {
+		if size := GetNodeSize(node); size > 0 {
+			bySize[size] = append(bySize[size], node)
+		}
+	}
+	for _, sized := range bySize {
+		if len(sized) < 2 {
+			continue
+		}
+		etags := make(map[string]bool)
+		multipart := false
+		for _, node := range sized {
+			etags[GetNodeETag(node)] = true
+			multipart = multipart || IsMultipartETag(GetNodeETag(node))
+		}
+		if len(etags) < 2 || !multipart {
+			continue
+		}
+		for _, node := range sized {
+			if _, ok := hashes[node]; !ok {
+				candidates = append(candidates, node)
+			}
+		}
+	}
+	return
+}
+
+func GetWastedBytes(groups []*DuplicateGroup) (wasted int64) {
+	for _, group := range groups {
+		wasted += group.Wasted()
+	}
+	return
+}
+
+func FormatDuplicateGroup(group *DuplicateGroup) string {
+
+	// Wasted bytes, copy count and the first copy's key
+
+	match := group.Match
+	if len(match) > 12 {
+		match = match[:12]
+	}
+	if group.Verified {
+		match = "sha256:" + match
+	}
+	return fmt.Sprintf("%10s wasted  %3d x %10s  %-19s  %s",
+		ByteFormat(float64(group.Wasted()), 1), len(group.Nodes), ByteFormat(float64(group.Size), 1),
+		match, *group.Nodes[0].S3Object.Key)
+}
+
+func (s S3Session) HashObject(bucket BucketWithDisplay, key string) (hash string, err error) {
+
+	// SHA-256 of an object's content, streamed
+
+	body, _, err := s.GetObjectVersionReader(bucket, key, "")
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, body); err != nil {
+		return
+	}
+	hash = hex.EncodeToString(hasher.Sum(nil))
+	log.Printf("Hashed %s: %s\n", key, hash)
+	return
+}
@@ -0,0 +1,69 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestGroupDuplicates(t *testing.T) {
+	node := func(key string, size int64, etag string) *Node {
+		return &Node{FullPath: key, S3Object: &s3.Object{Key: aws.String(key), Size: aws.Int64(size), ETag: aws.String("\"" + etag + "\"")}}
+	}
+	nodes := []*Node{
+		node("b.jpg", 100, "aa"),
+		node("a.jpg", 100, "aa"),
+		node("c.jpg", 100, "bb"),
+		node("big1.iso", 1000, "cc"),
+		node("big2.iso", 1000, "cc"),
+		node("big3.iso", 1000, "cc"),
+		node("empty1", 0, "dd"),
+		node("empty2", 0, "dd"),
+		node("part1.bin", 50, "ee-2"),
+		node("part2.bin", 50, "ff-3"),
+	}
+
+	// Content hashes of the multipart objects match even though their ETags don't
+
+	hashes := map[*Node]string{nodes[8]: "hash", nodes[9]: "hash"}
+	groups := GroupDuplicates(nodes, hashes)
+	var got [][]string
+	var wasted []int64
+	for _, group := range groups {
+		var keys []string
+		for _, n := range group.Nodes {
+			keys = append(keys, *n.S3Object.Key)
+		}
+		got = append(got, keys)
+		wasted = append(wasted, group.Wasted())
+	}
+	want := [][]string{{"big1.iso", "big2.iso", "big3.iso"}, {"a.jpg", "b.jpg"}, {"part1.bin", "part2.bin"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got groups %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(wasted, []int64{2000, 100, 50}) {
+		t.Errorf("got wasted %v", wasted)
+	}
+	if len(groups) == 3 && (!groups[2].Verified || groups[0].Verified) {
+		t.Error("only the hashed group should be verified")
+	}
+}
@@ -0,0 +1,328 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/gizak/termui"
+)
+
+type DuplicateFinder struct {
+	explorer *BucketExplorer
+	location string
+	nodes    []*Node
+	hashes   map[*Node]string
+	groups   []*DuplicateGroup
+}
+
+func RenderDuplicates(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Group everything under the directory by size and ETag
+
+	finder := &DuplicateFinder{
+		explorer: explorer,
+		location: fmt.Sprintf("s3://%s/%s", *explorer.bucket.bucket.Name, GetNodePrefix(dir)),
+		nodes:    GetReportNodes(dir),
+		hashes:   make(map[*Node]string),
+	}
+	finder.groups = GroupDuplicates(finder.nodes, finder.hashes)
+	RenderDuplicateGroups(finder, 0, back)
+}
+
+func GetDuplicatesTitle(finder *DuplicateFinder) string {
+	title := fmt.Sprintf("Duplicates in %s: %d groups, %s wasted",
+		finder.location, len(finder.groups), ByteFormat(float64(GetWastedBytes(finder.groups)), 1))
+	if candidates := GetHashCandidates(finder.nodes, finder.hashes); len(candidates) > 0 {
+		title = fmt.Sprintf("%s (%d multipart objects unchecked)", title, len(candidates))
+	}
+	return title
+}
+
+func RenderDuplicatesHelp() *termui.Par {
+	return RenderHelp("<h> hash multipart objects")
+}
+
+func RenderDuplicateGroups(finder *DuplicateFinder, selection int, back func()) {
+
+	if selection >= len(finder.groups) {
+		selection = len(finder.groups) - 1
+	}
+	if selection < 0 {
+		selection = 0
+	}
+	title := GetDuplicatesTitle(finder)
+	var lines []string
+	for _, group := range finder.groups {
+		lines = append(lines, FormatDuplicateGroup(group))
+	}
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderDuplicatesHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(finder.explorer.deferFunc)
+	SetBackHandler(back)
+
+	current := func() {
+		RenderDuplicateGroups(finder, selection, back)
+	}
+
+	// h hashes objects whose multipart ETags can't be compared
+
+	termui.Handle("/sys/kbd/h", func(termui.Event) {
+		RenderHashDuplicates(finder, current)
+	})
+
+	if len(finder.groups) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderDuplicatesHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(finder.groups)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderDuplicatesHelp())
+		}
+	})
+
+	// Enter opens the group to pick which copies to keep
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		RenderDuplicateGroup(finder, finder.groups[selection], 0, make(map[*Node]bool), current)
+	})
+}
+
+func RenderHashDuplicates(finder *DuplicateFinder, back func()) {
+
+	// Confirm, then download and hash each candidate with progress
+
+	candidates := GetHashCandidates(finder.nodes, finder.hashes)
+	if len(candidates) == 0 {
+		back()
+		termui.Render(CreateStatusPrompt("No multipart objects need hashing"))
+		return
+	}
+	var total int64
+	for _, node := range candidates {
+		total += GetNodeSize(node)
+	}
+	msg := fmt.Sprintf("Download and hash %d objects (%s) to compare their content?", len(candidates), ByteFormat(float64(total), 1))
+	RenderConfirmPrompt("Hash Objects", msg, func() {
+		sess, err := InitBucketSession(finder.explorer.bucket)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+		termui.Clear()
+		var failures []string
+		for i, node := range candidates {
+			termui.Render(CreateProgressGauge("Hashing objects", i, len(candidates)))
+			hash, err := sess.HashObject(finder.explorer.bucket, *node.S3Object.Key)
+			if err != nil {
+				log.Println(err)
+				failures = append(failures, fmt.Sprintf("%s: %s", *node.S3Object.Key, err.Error()))
+				continue
+			}
+			finder.hashes[node] = hash
+		}
+		finder.groups = GroupDuplicates(finder.nodes, finder.hashes)
+		if len(failures) > 0 {
+			lines := append([]string{fmt.Sprintf("%d objects could not be hashed:", len(failures))}, failures...)
+			RenderTextViewer("Hash Failures", lines, back)
+			return
+		}
+		back()
+	}, back)
+}
+
+func RenderDuplicateGroupHelp() *termui.Par {
+	return RenderHelp("<space> select", "<k> keep selected", "<x> delete selected")
+}
+
+func RenderDuplicateGroup(finder *DuplicateFinder, group *DuplicateGroup, selection int, selected map[*Node]bool, back func()) {
+
+	// The copies in a group. Select some, then keep them or delete them.
+
+	var lines []string
+	for _, node := range group.Nodes {
+		check := "[ ]"
+		if selected[node] {
+			check = "[x]"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s", check, FormatReportNode(node)))
+	}
+	title := fmt.Sprintf("%d copies of %s (%s)", len(group.Nodes), ByteFormat(float64(group.Size), 1), group.Match)
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderDuplicateGroupHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(finder.explorer.deferFunc)
+	SetBackHandler(back)
+
+	current := func() {
+		RenderDuplicateGroup(finder, group, selection, selected, back)
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderDuplicateGroupHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(group.Nodes)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderDuplicateGroupHelp())
+		}
+	})
+
+	// space selects or unselects a copy
+
+	termui.Handle("/sys/kbd/<space>", func(termui.Event) {
+		node := group.Nodes[selection]
+		if selected[node] {
+			delete(selected, node)
+		} else {
+			selected[node] = true
+		}
+		if selection < len(group.Nodes)-1 {
+			selection += 1
+		}
+		current()
+	})
+
+	// Enter shows the copy in the explorer
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		JumpToNode(finder.explorer, group.Nodes[selection])
+	})
+
+	// x deletes the selected copies, k deletes everything else
+
+	termui.Handle("/sys/kbd/x", func(termui.Event) {
+		var targets []*Node
+		for _, node := range group.Nodes {
+			if selected[node] {
+				targets = append(targets, node)
+			}
+		}
+		DeleteDuplicates(finder, group, targets, current, back)
+	})
+
+	termui.Handle("/sys/kbd/k", func(termui.Event) {
+		if len(selected) == 0 {
+			RenderError("Select the copies to keep with <space> first")
+			current()
+			return
+		}
+		var targets []*Node
+		for _, node := range group.Nodes {
+			if !selected[node] {
+				targets = append(targets, node)
+			}
+		}
+		DeleteDuplicates(finder, group, targets, current, back)
+	})
+}
+
+func DeleteDuplicates(finder *DuplicateFinder, group *DuplicateGroup, targets []*Node, cancel func(), done func()) {
+
+	// Confirm and delete copies, always leaving at least one
+
+	if len(targets) == 0 {
+		RenderError("No copies selected")
+		cancel()
+		return
+	}
+	if len(targets) == len(group.Nodes) {
+		RenderError("At least one copy has to be kept")
+		cancel()
+		return
+	}
+	msg := fmt.Sprintf("Delete %d copies, freeing %s?", len(targets), ByteFormat(float64(group.Size*int64(len(targets))), 1))
+	RenderConfirmPrompt("Delete Duplicates", msg, func() {
+		explorer := finder.explorer
+		sess, err := InitBucketSession(explorer.bucket)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			cancel()
+			return
+		}
+		var ids []*s3.ObjectIdentifier
+		for _, node := range targets {
+			ids = append(ids, &s3.ObjectIdentifier{Key: node.S3Object.Key})
+		}
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Deleting %d copies", len(ids))))
+		errs, err := sess.DeleteObjectBatchErrors(explorer.bucket, ids, nil)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			cancel()
+			return
+		}
+
+		// Update the tree and the groups for what was actually deleted
+
+		failed := make(map[string]string)
+		for _, e := range errs {
+			failed[aws.StringValue(e.Key)] = aws.StringValue(e.Message)
+		}
+		deleted := make(map[*Node]bool)
+		for _, node := range targets {
+			if _, ok := failed[*node.S3Object.Key]; ok {
+				continue
+			}
+			deleted[node] = true
+			if explorer.showDeleted {
+				node.IsDeleted = true
+			} else {
+				RemoveNode(node)
+			}
+		}
+		var remaining []*Node
+		for _, node := range finder.nodes {
+			if !deleted[node] {
+				remaining = append(remaining, node)
+			}
+		}
+		finder.nodes = remaining
+		finder.groups = GroupDuplicates(finder.nodes, finder.hashes)
+
+		if len(failed) > 0 {
+			lines := []string{fmt.Sprintf("Deleted %d copies, %d failed:", len(deleted), len(failed))}
+			for key, message := range failed {
+				lines = append(lines, fmt.Sprintf("%s: %s", key, message))
+			}
+			RenderTextViewer("Delete Failures", lines, done)
+			return
+		}
+		done()
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Deleted %d copies", len(deleted))))
+	}, cancel)
+}
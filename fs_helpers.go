@@ -17,14 +17,154 @@ along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
 
 package main
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
 
 func FileExists(path string) bool {
 
 	// Return true if file exists on system
 
-	if _, err := os.Stat(logFile); err == nil {
+	if _, err := os.Stat(path); err == nil {
 		return true
 	}
 	return false
 }
+
+func LoadLocalDirectory(dir string) (node *Node, err error) {
+
+	// Read one level of a local directory into a node. The parent is a
+	// childless placeholder so ".." can be shown and followed.
+
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	log.Printf("Loading local directory: %s\n", dir)
+	stat, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	info, display := fileInfoFromInterface(stat)
+	node = &Node{FullPath: dir, DisplayString: display, Info: info}
+	for _, entry := range entries {
+		childInfo, childDisplay := fileInfoFromInterface(entry)
+		node.Children = append(node.Children, &Node{
+			FullPath:      filepath.Join(dir, entry.Name()),
+			DisplayString: childDisplay,
+			Info:          childInfo,
+			Parent:        node,
+		})
+	}
+	if parent := filepath.Dir(dir); parent != dir {
+		if parentStat, err := os.Stat(parent); err == nil {
+			parentInfo, parentDisplay := fileInfoFromInterface(parentStat)
+			node.Parent = &Node{FullPath: parent, DisplayString: parentDisplay, Info: parentInfo}
+		}
+	}
+	return
+}
+
+func CopyLocalFile(src string, dest string) (err error) {
+
+	// Copy a file, creating the destination's directories and keeping the
+	// modification time. The copy is written next to dest and renamed over
+	// it, so a failure leaves an existing dest alone.
+
+	log.Printf("Copying %s to %s\n", src, dest)
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return
+	}
+	err = os.MkdirAll(filepath.Dir(dest), DEFAULT_DIRECTORY_MODE)
+	if err != nil {
+		return
+	}
+	out, err := ioutil.TempFile(filepath.Dir(dest), ".s3explorer-copy-")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(out.Name(), stat.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Chtimes(out.Name(), stat.ModTime(), stat.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dest)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return
+}
+
+func ValidateLocalName(name string) error {
+
+	// A single path element for new directories and renames
+
+	switch {
+	case name == "" || name == "." || name == "..":
+		return errors.New("Enter a name")
+	case strings.ContainsAny(name, "/"+localDelimiter):
+		return errors.New("Names can't contain a path separator")
+	}
+	return nil
+}
+
+func MakeLocalDirectory(parent string, name string) error {
+	if err := ValidateLocalName(name); err != nil {
+		return err
+	}
+	log.Printf("Creating local directory %s in %s\n", name, parent)
+	return os.Mkdir(filepath.Join(parent, name), DEFAULT_DIRECTORY_MODE)
+}
+
+func RenameLocalPath(path string, name string) error {
+
+	// Rename in place, never over something that exists
+
+	if err := ValidateLocalName(name); err != nil {
+		return err
+	}
+	dest := filepath.Join(filepath.Dir(path), name)
+	if FileExists(dest) {
+		return fmt.Errorf("%s already exists", dest)
+	}
+	log.Printf("Renaming %s to %s\n", path, dest)
+	return os.Rename(path, dest)
+}
+
+func CountLocalFiles(path string) (files int, size int64) {
+
+	// Files and bytes at or under a path
+
+	filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			files += 1
+			size += info.Size()
+		}
+		return nil
+	})
+	return
+}
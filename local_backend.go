@@ -0,0 +1,383 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Local directories served as buckets. Subdirectories are prefixes and
+// files are objects. Empty directories show up as zero byte "dir/" keys,
+// the way folders made in the S3 console do.
+
+type LocalBackend struct {
+	roots map[string]string // bucket name to directory
+	etags map[string]localETag
+	mutex *sync.Mutex
+}
+
+// ETags are md5 sums, only recomputed when a file's size or mtime changes
+
+type localETag struct {
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+func NewLocalBackend(specs []string) (backend LocalBackend, err error) {
+
+	// Each spec is a directory, or name=directory to choose the bucket name
+
+	backend = LocalBackend{
+		roots: make(map[string]string),
+		etags: make(map[string]localETag),
+		mutex: &sync.Mutex{},
+	}
+	for _, spec := range specs {
+		name, dir := "", spec
+		if i := strings.Index(spec, "="); i > 0 {
+			name, dir = spec[:i], spec[i+1:]
+		}
+		dir, err = filepath.Abs(dir)
+		if err != nil {
+			return
+		}
+		info, statErr := os.Stat(dir)
+		if statErr != nil || !info.IsDir() {
+			return backend, errors.New(fmt.Sprintf("Not a directory: %s", dir))
+		}
+		if name == "" {
+			name = filepath.Base(dir)
+		}
+		if _, ok := backend.roots[name]; ok {
+			return backend, errors.New(fmt.Sprintf("Local bucket %s given twice, use name=directory to rename one", name))
+		}
+		log.Printf("Serving %s as local bucket %s\n", dir, name)
+		backend.roots[name] = dir
+	}
+	return
+}
+
+func (b LocalBackend) Name() string {
+	return BACKEND_LOCAL
+}
+
+func (b LocalBackend) HasBucket(name string) bool {
+	_, ok := b.roots[name]
+	return ok
+}
+
+func (b LocalBackend) getRoot(bucket string) (root string, err error) {
+	root, ok := b.roots[bucket]
+	if !ok {
+		err = awserr.New(s3.ErrCodeNoSuchBucket, fmt.Sprintf("The specified bucket does not exist: %s", bucket), nil)
+	}
+	return
+}
+
+func (b LocalBackend) getPath(bucket string, key string) (path string, err error) {
+
+	// Keys must stay inside the bucket's directory
+
+	root, err := b.getRoot(bucket)
+	if err != nil {
+		return
+	}
+	path = filepath.Join(root, filepath.FromSlash(key))
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		err = errors.New(fmt.Sprintf("Key leaves the bucket directory: %s", key))
+	}
+	return
+}
+
+func (b LocalBackend) getETag(path string, info os.FileInfo) (etag string, err error) {
+	b.mutex.Lock()
+	cached, ok := b.etags[path]
+	b.mutex.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.etag, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	hasher := md5.New()
+	if _, err = io.Copy(hasher, file); err != nil {
+		return
+	}
+	etag = fmt.Sprintf("\"%s\"", hex.EncodeToString(hasher.Sum(nil)))
+	b.mutex.Lock()
+	b.etags[path] = localETag{size: info.Size(), modTime: info.ModTime(), etag: etag}
+	b.mutex.Unlock()
+	return
+}
+
+func (b LocalBackend) ListBuckets() (buckets []*s3.Bucket, err error) {
+	for name, root := range b.roots {
+		bucket := &s3.Bucket{Name: aws.String(name)}
+		if info, err := os.Stat(root); err == nil {
+			bucket.CreationDate = aws.Time(info.ModTime())
+		}
+		buckets = append(buckets, bucket)
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return *buckets[i].Name < *buckets[j].Name
+	})
+	return
+}
+
+func (b LocalBackend) GetBucketRegion(bucket string) (region string, err error) {
+	if _, err = b.getRoot(bucket); err != nil {
+		return
+	}
+	return LOCAL_BUCKET_REGION, nil
+}
+
+func (b LocalBackend) ListObjects(bucket string, prefix string, delimiter string) (objects []*s3.Object, prefixes []string, err error) {
+
+	// Walk the whole directory, then filter and fold like S3 does
+
+	root, err := b.getRoot(bucket)
+	if err != nil {
+		return
+	}
+	var all []*s3.Object
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		object := &s3.Object{
+			LastModified: aws.Time(info.ModTime()),
+			StorageClass: aws.String(s3.StorageClassStandard),
+		}
+		switch {
+		case info.IsDir():
+			entries, err := ioutil.ReadDir(path)
+			if err != nil || len(entries) > 0 {
+				return err
+			}
+			object.Key = aws.String(key + "/")
+			object.Size = aws.Int64(0)
+			object.ETag = aws.String(GetMemoryETag(nil))
+		case info.Mode().IsRegular():
+
+			// Don't hash files that are going to be filtered out
+
+			if !strings.HasPrefix(key, prefix) {
+				return nil
+			}
+			etag, err := b.getETag(path, info)
+			if err != nil {
+				return err
+			}
+			object.Key = aws.String(key)
+			object.Size = aws.Int64(info.Size())
+			object.ETag = aws.String(etag)
+		default:
+			return nil
+		}
+		all = append(all, object)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return *all[i].Key < *all[j].Key
+	})
+	seen := make(map[string]bool)
+	for _, object := range all {
+		key := *object.Key
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					prefixes = append(prefixes, common)
+				}
+				continue
+			}
+		}
+		objects = append(objects, object)
+	}
+	return
+}
+
+func (b LocalBackend) GetObject(bucket string, key string, versionId string) (body io.ReadCloser, contentType string, err error) {
+	path, err := b.getPath(bucket, key)
+	if err != nil {
+		return
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		err = awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("The specified key does not exist: %s", key), err)
+	}
+	if err != nil {
+		return
+	}
+	return file, mime.TypeByExtension(filepath.Ext(path)), nil
+}
+
+func (b LocalBackend) DownloadObject(bucket string, key string, versionId string, dest io.WriterAt) (n int64, err error) {
+	body, _, err := b.GetObject(bucket, key, versionId)
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	return CopyToWriterAt(dest, body)
+}
+
+func (b LocalBackend) PutObject(bucket string, key string, body io.Reader, contentType string) (err error) {
+
+	// Write next to the destination then rename, so readers never see half a file
+
+	path, err := b.getPath(bucket, key)
+	if err != nil {
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		return os.MkdirAll(path, DEFAULT_DIRECTORY_MODE)
+	}
+	if err = os.MkdirAll(filepath.Dir(path), DEFAULT_DIRECTORY_MODE); err != nil {
+		return
+	}
+	temp, err := ioutil.TempFile(filepath.Dir(path), ".s3explorer-upload-")
+	if err != nil {
+		return
+	}
+	_, err = io.Copy(temp, body)
+	temp.Close()
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+	return
+}
+
+func (b LocalBackend) CopyObject(srcBucket string, srcKey string, destBucket string, destKey string) (err error) {
+	body, contentType, err := b.GetObject(srcBucket, srcKey, "")
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	return b.PutObject(destBucket, destKey, body, contentType)
+}
+
+func (b LocalBackend) DeleteObject(bucket string, key string) (err error) {
+
+	// Remove the file and any directories it leaves empty, since prefixes
+	// only exist while something is under them. Missing keys are not an error.
+
+	path, err := b.getPath(bucket, key)
+	if err != nil {
+		return
+	}
+	root, _ := b.getRoot(bucket)
+	if path == root {
+		return
+	}
+	log.Printf("Removing local object %s\n", path)
+	if err = os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	for dir := filepath.Dir(path); dir != root; dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return
+}
+
+func (b LocalBackend) DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) (errs []*s3.Error, err error) {
+	for _, id := range ids {
+		if deleteErr := b.DeleteObject(bucket, aws.StringValue(id.Key)); deleteErr != nil {
+			errs = append(errs, &s3.Error{
+				Key:       id.Key,
+				VersionId: id.VersionId,
+				Code:      aws.String("InternalError"),
+				Message:   aws.String(deleteErr.Error()),
+			})
+		}
+	}
+	return
+}
+
+func (b LocalBackend) HeadObject(bucket string, key string) (out *s3.HeadObjectOutput, err error) {
+	path, err := b.getPath(bucket, key)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() != strings.HasSuffix(key, "/") {
+		return nil, awserr.New("NotFound", fmt.Sprintf("Not found: %s", key), err)
+	}
+	etag := GetMemoryETag(nil)
+	if !info.IsDir() {
+		if etag, err = b.getETag(path, info); err != nil {
+			return
+		}
+	}
+	out = &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(info.Size()),
+		ETag:          aws.String(etag),
+		LastModified:  aws.Time(info.ModTime()),
+		StorageClass:  aws.String(s3.StorageClassStandard),
+		Metadata:      make(map[string]*string),
+	}
+	if info.IsDir() {
+		out.ContentLength = aws.Int64(0)
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		out.ContentType = aws.String(contentType)
+	}
+	return
+}
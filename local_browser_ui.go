@@ -0,0 +1,311 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/gizak/termui"
+)
+
+type LocalBrowser struct {
+	explorer  *BucketExplorer
+	uploadDir *Node // the explorer directory uploads go to
+	marked    map[*Node]bool
+	modified  bool // something was uploaded, so the tree is stale
+}
+
+func RenderLocalBrowser(explorer *BucketExplorer, uploadDir *Node, back func()) {
+
+	// Start in the download target
+
+	dir, err := LoadLocalDirectory(downloadDir)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	browser := &LocalBrowser{explorer: explorer, uploadDir: uploadDir, marked: make(map[*Node]bool)}
+	RenderLocalBrowserListing(browser, dir, 0, back)
+}
+
+func GetLocalBrowserTitle(browser *LocalBrowser, dir *Node) string {
+	title := fmt.Sprintf("Local: %s", dir.FullPath)
+	if dir.FullPath == downloadDir {
+		title += " [download target]"
+	}
+	if len(browser.marked) > 0 {
+		title = fmt.Sprintf("%s [%d marked]", title, len(browser.marked))
+	}
+	return title
+}
+
+func RenderLocalBrowserHelp() *termui.Par {
+	return RenderHelp("<space> mark", "<u> upload", "<t> download here", "<n> new dir", "<R> rename", "<x> delete")
+}
+
+func RenderLocalBrowserListing(browser *LocalBrowser, dir *Node, selection int, back func()) {
+
+	nodes := GetNodeDirectory(dir)
+	if selection >= len(nodes) {
+		selection = len(nodes) - 1
+	}
+	if selection < 0 {
+		selection = 0
+	}
+	title := GetLocalBrowserTitle(browser, dir)
+	termui.Clear()
+	termui.Render(CreateDirectoryList(title, nodes, selection, browser.marked), RenderLocalBrowserHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(browser.explorer.deferFunc)
+
+	// Changes re-read the directory
+
+	reload := func() {
+		browser.marked = make(map[*Node]bool)
+		fresh, err := LoadLocalDirectory(dir.FullPath)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			fresh = dir
+		}
+		RenderLocalBrowserListing(browser, fresh, selection, back)
+	}
+	current := func() {
+		RenderLocalBrowserListing(browser, dir, selection, back)
+	}
+
+	// Uploads change the bucket, so reload it rather than going back to a
+	// stale tree
+
+	SetBackHandler(func() {
+		if browser.modified {
+			browser.explorer.deferFunc()
+			LoadBucketExplorer(browser.explorer.bucket, browser.explorer.showDeleted)
+			return
+		}
+		back()
+	})
+
+	// t makes this directory the download target
+
+	termui.Handle("/sys/kbd/t", func(termui.Event) {
+		SetDownloadTarget(dir.FullPath)
+		current()
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Downloads now go to %s", dir.FullPath)))
+	})
+
+	// n creates a directory here
+
+	termui.Handle("/sys/kbd/n", func(termui.Event) {
+		PromptLocalMkdir(dir.FullPath, reload, current)
+	})
+
+	if len(nodes) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateDirectoryList(title, nodes, selection, browser.marked), RenderLocalBrowserHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(nodes)-1 {
+			selection += 1
+			termui.Render(CreateDirectoryList(title, nodes, selection, browser.marked), RenderLocalBrowserHelp())
+		}
+	})
+
+	// Enter opens a directory (".." is the parent)
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		if !nodes[selection].Info.IsDir {
+			return
+		}
+		target, err := LoadLocalDirectory(nodes[selection].FullPath)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			return
+		}
+		browser.marked = make(map[*Node]bool)
+		RenderLocalBrowserListing(browser, target, 0, back)
+	})
+
+	// space marks or unmarks the selection
+
+	termui.Handle("/sys/kbd/<space>", func(termui.Event) {
+		target := nodes[selection]
+		if target.DisplayString == ".." {
+			return
+		}
+		if browser.marked[target] {
+			delete(browser.marked, target)
+		} else {
+			browser.marked[target] = true
+		}
+		if selection < len(nodes)-1 {
+			selection += 1
+		}
+		current()
+	})
+
+	// R renames the selection
+
+	termui.Handle("/sys/kbd/R", func(termui.Event) {
+		if nodes[selection].DisplayString == ".." {
+			return
+		}
+		PromptLocalRename(nodes[selection], reload, current)
+	})
+
+	// x deletes the marked entries or the selection
+
+	termui.Handle("/sys/kbd/x", func(termui.Event) {
+		pane := &Pane{Local: true, Dir: dir, Selection: selection, Marked: browser.marked}
+		targets := pane.Targets()
+		if len(targets) == 0 {
+			return
+		}
+		ConfirmLocalDelete(targets, reload, current)
+	})
+
+	// u uploads the marked entries or the selection to the explorer's
+	// directory
+
+	termui.Handle("/sys/kbd/u", func(termui.Event) {
+		src := &Pane{Local: true, Dir: dir, Selection: selection, Marked: browser.marked}
+		targets := src.Targets()
+		if len(targets) == 0 {
+			return
+		}
+		UploadLocalTargets(browser, src, targets, reload, current)
+	})
+}
+
+func SetDownloadTarget(dir string) {
+	log.Printf("Setting download target to %s\n", dir)
+	downloadDir = dir
+}
+
+func PromptLocalMkdir(parent string, done func(), cancel func()) {
+	RenderInputPrompt(fmt.Sprintf("New directory in %s", parent), "", func(name string) {
+		if err := MakeLocalDirectory(parent, name); err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			cancel()
+			return
+		}
+		done()
+	}, cancel)
+}
+
+func PromptLocalRename(node *Node, done func(), cancel func()) {
+	RenderInputPrompt(fmt.Sprintf("Rename %s to", node.Info.Name), node.Info.Name, func(name string) {
+		if name == node.Info.Name {
+			cancel()
+			return
+		}
+		if err := RenameLocalPath(node.FullPath, name); err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			cancel()
+			return
+		}
+		done()
+	}, cancel)
+}
+
+func ConfirmLocalDelete(targets []*Node, done func(), cancel func()) {
+
+	// Say how much is under the targets before removing them
+
+	var files int
+	var size int64
+	for _, target := range targets {
+		count, bytes := CountLocalFiles(target.FullPath)
+		files += count
+		size += bytes
+	}
+	message := fmt.Sprintf("Permanently delete %s (%d files, %s)?", targets[0].FullPath, files, ByteFormat(float64(size), 1))
+	if len(targets) > 1 {
+		message = fmt.Sprintf("Permanently delete %d entries (%d files, %s)?", len(targets), files, ByteFormat(float64(size), 1))
+	}
+	RenderConfirmPrompt("Delete", message, func() {
+		for _, target := range targets {
+			log.Printf("Deleting local path %s\n", target.FullPath)
+			if err := os.RemoveAll(target.FullPath); err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				break
+			}
+		}
+		done()
+	}, cancel)
+}
+
+func UploadLocalTargets(browser *LocalBrowser, src *Pane, targets []*Node, done func(), cancel func()) {
+
+	// Upload with the commander's transfer helpers into the explorer's
+	// directory
+
+	bucket := browser.explorer.bucket
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+	dest := &Pane{Bucket: bucket, Session: sess, Dir: browser.uploadDir}
+	transfers, err := GetPaneTransfers(src, dest, targets)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+	if len(transfers) == 0 {
+		RenderError("Nothing to upload")
+		return
+	}
+	message := fmt.Sprintf("Upload %d files (%s) to %s? Existing objects are overwritten.",
+		len(transfers), ByteFormat(float64(GetPaneTransferSize(transfers)), 1), dest.Location())
+	RenderConfirmPrompt("Upload", message, func() {
+		termui.Clear()
+		var failures []string
+		for i, transfer := range transfers {
+			termui.Render(CreateProgressGauge("Uploading files", i, len(transfers)))
+			if err := RunPaneTransfer(src, dest, transfer); err != nil {
+				log.Println(err)
+				failures = append(failures, fmt.Sprintf("%s: %s", transfer.Source, err))
+				continue
+			}
+			browser.modified = true
+		}
+		if len(failures) > 0 {
+			RenderTextViewer("Upload Failures", failures, done)
+			return
+		}
+		done()
+	}, cancel)
+}
@@ -0,0 +1,301 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type LocalFile struct {
+	RelPath string // slash separated, relative to the compared directory
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+type FileDiff struct {
+	RelPath     string
+	Status      string // one of the DIFF_* constants
+	Reason      string // why a file counts as changed
+	RemoteNewer bool   // the object was modified after the local file
+	Local       *LocalFile
+	Object      *s3.Object
+	Remote      *Node // set when compared against the explorer's tree
+}
+
+// Part sizes (in MB) commonly used by S3 clients for multipart uploads
+
+var commonPartSizesMB = []int64{5, 8, 10, 15, 16, 25, 32, 50, 64, 100, 128, 256, 512}
+
+func ListLocalFiles(root string) (files map[string]*LocalFile, err error) {
+
+	// Every regular file under root keyed by its slash separated relative path
+
+	log.Printf("Listing local files under %s\n", root)
+	files = make(map[string]*LocalFile)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		files[rel] = &LocalFile{RelPath: rel, Path: path, Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	return
+}
+
+func ComputeFileMD5(path string) (sum string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	hasher := md5.New()
+	if _, err = io.Copy(hasher, file); err != nil {
+		return
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func ComputeMultipartETag(path string, partSize int64) (etag string, err error) {
+
+	// The MD5 of the concatenated part MD5s, with the part count appended
+
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	var sums []byte
+	parts := 0
+	for {
+		hasher := md5.New()
+		n, err := io.CopyN(hasher, file, partSize)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if n == 0 {
+			break
+		}
+		sums = append(sums, hasher.Sum(nil)...)
+		parts += 1
+		if n < partSize {
+			break
+		}
+	}
+	total := md5.Sum(sums)
+	return fmt.Sprintf("%s-%d", hex.EncodeToString(total[:]), parts), nil
+}
+
+func GetMultipartPartSizes(size int64, parts int64) (sizes []int64) {
+
+	// Part sizes that would split size into exactly this many parts: the
+	// common client defaults plus the size divided evenly rounded up to a MB
+
+	const mb = 1024 * 1024
+	candidates := []int64{}
+	for _, partMB := range commonPartSizesMB {
+		candidates = append(candidates, partMB*mb)
+	}
+	even := (size + parts - 1) / parts
+	candidates = append(candidates, (even+mb-1)/mb*mb)
+
+	seen := make(map[int64]bool)
+	for _, partSize := range candidates {
+		if seen[partSize] || partSize <= 0 {
+			continue
+		}
+		seen[partSize] = true
+		if (size+partSize-1)/partSize == parts {
+			sizes = append(sizes, partSize)
+		}
+	}
+	return
+}
+
+func MatchesETag(path string, size int64, etag string) (match bool, err error) {
+
+	// Compare a local file with an ETag, working out the part size for
+	// multipart uploads
+
+	if !IsMultipartETag(etag) {
+		var sum string
+		sum, err = ComputeFileMD5(path)
+		return sum == etag, err
+	}
+	parts, err := strconv.ParseInt(etag[strings.LastIndex(etag, "-")+1:], 10, 64)
+	if err != nil {
+		return false, err
+	}
+	for _, partSize := range GetMultipartPartSizes(size, parts) {
+		var local string
+		local, err = ComputeMultipartETag(path, partSize)
+		if err != nil {
+			return
+		}
+		if local == etag {
+			log.Printf("Matched %s with part size %d\n", path, partSize)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func CompareLocalPrefix(root string, dir *Node, checksum bool, progress func(done int, total int)) (diffs []FileDiff, err error) {
+
+	// Compare a local directory with everything under a directory node
+
+	nodes := make(map[string]*Node)
+	var objects []*s3.Object
+	for _, node := range GetReportNodes(dir) {
+		nodes[*node.S3Object.Key] = node
+		objects = append(objects, node.S3Object)
+	}
+	diffs, err = CompareLocalObjects(root, GetNodePrefix(dir), objects, nil, checksum, progress)
+	for i := range diffs {
+		if diffs[i].Object != nil {
+			diffs[i].Remote = nodes[*diffs[i].Object.Key]
+		}
+	}
+	return
+}
+
+func CompareLocalObjects(root string, prefix string, objects []*s3.Object, allow func(rel string) bool, checksum bool, progress func(done int, total int)) (diffs []FileDiff, err error) {
+
+	// Compare a local directory with the objects under a prefix by relative
+	// path. Files count as changed when the sizes differ, when the local
+	// copy was modified after the upload, or with checksum set when the
+	// content doesn't match the ETag. Paths allow rejects are left out.
+
+	local, err := ListLocalFiles(root)
+	if err != nil {
+		return
+	}
+	remote := make(map[string]*s3.Object)
+	for _, object := range objects {
+		if strings.HasSuffix(*object.Key, "/") {
+			continue
+		}
+		remote[strings.TrimPrefix(*object.Key, prefix)] = object
+	}
+	if allow != nil {
+		for rel := range local {
+			if !allow(rel) {
+				delete(local, rel)
+			}
+		}
+		for rel := range remote {
+			if !allow(rel) {
+				delete(remote, rel)
+			}
+		}
+	}
+
+	done := 0
+	for rel, file := range local {
+		if progress != nil {
+			progress(done, len(local))
+		}
+		done += 1
+		object, ok := remote[rel]
+		if !ok {
+			diffs = append(diffs, FileDiff{RelPath: rel, Status: DIFF_ONLY_LOCAL, Local: file})
+			continue
+		}
+		size := aws.Int64Value(object.Size)
+		modified := aws.TimeValue(object.LastModified).Truncate(time.Second)
+		localModified := file.ModTime.Truncate(time.Second)
+		diff := FileDiff{
+			RelPath:     rel,
+			Status:      DIFF_SAME,
+			RemoteNewer: modified.After(localModified),
+			Local:       file,
+			Object:      object,
+		}
+		switch {
+		case file.Size != size:
+			diff.Status, diff.Reason = DIFF_CHANGED, "size"
+		case checksum:
+			match, err := MatchesETag(file.Path, file.Size, strings.Trim(aws.StringValue(object.ETag), "\""))
+			if err != nil {
+				return nil, err
+			}
+			if !match {
+				diff.Status, diff.Reason = DIFF_CHANGED, "content"
+			}
+		case localModified.After(modified):
+			diff.Status, diff.Reason = DIFF_CHANGED, "local newer"
+		}
+		diffs = append(diffs, diff)
+	}
+	for rel, object := range remote {
+		if _, ok := local[rel]; !ok {
+			diffs = append(diffs, FileDiff{RelPath: rel, Status: DIFF_ONLY_REMOTE, Object: object})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].RelPath < diffs[j].RelPath
+	})
+	return
+}
+
+func CountDiffs(diffs []FileDiff) map[string]int {
+	counts := make(map[string]int)
+	for _, diff := range diffs {
+		counts[diff.Status] += 1
+	}
+	return counts
+}
+
+func FormatFileDiff(diff FileDiff) string {
+
+	// Status, local and remote sizes, and the path
+
+	status := diff.Status
+	if diff.Reason != "" {
+		status = fmt.Sprintf("%s (%s)", status, diff.Reason)
+	}
+	localSize, remoteSize := "-", "-"
+	if diff.Local != nil {
+		localSize = ByteFormat(float64(diff.Local.Size), 1)
+	}
+	if diff.Object != nil {
+		remoteSize = ByteFormat(float64(aws.Int64Value(diff.Object.Size)), 1)
+	}
+	return fmt.Sprintf("%-24s %10s %10s  %s", status, localSize, remoteSize, diff.RelPath)
+}
@@ -0,0 +1,230 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gizak/termui"
+)
+
+type LocalDiff struct {
+	explorer *BucketExplorer
+	dir      *Node
+	root     string
+	diffs    []FileDiff // differences only
+	same     int
+	modified bool // something was uploaded, so the tree is stale
+}
+
+func RenderLocalDiff(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Ask for the local directory and how closely to compare
+
+	location := fmt.Sprintf("s3://%s/%s", *explorer.bucket.bucket.Name, GetNodePrefix(dir))
+	RenderInputPrompt(fmt.Sprintf("Local directory to compare with %s", location), downloadDir, func(root string) {
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			RenderError(fmt.Sprintf("Not a directory: %s", root))
+			back()
+			return
+		}
+		modes := []string{"Size and modification time", "Size and content (reads every file present on both sides)"}
+		RenderChoicePrompt("Compare by", modes, func(choice int) {
+			RunLocalDiff(explorer, dir, root, choice == 1, back)
+		}, back)
+	}, back)
+}
+
+func RunLocalDiff(explorer *BucketExplorer, dir *Node, root string, checksum bool, back func()) {
+
+	// Compare with progress and show the differences
+
+	termui.Clear()
+	diffs, err := CompareLocalPrefix(root, dir, checksum, func(done int, total int) {
+		termui.Render(CreateProgressGauge("Comparing files", done, total))
+	})
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	state := &LocalDiff{explorer: explorer, dir: dir, root: root}
+	for _, diff := range diffs {
+		if diff.Status == DIFF_SAME {
+			state.same += 1
+		} else {
+			state.diffs = append(state.diffs, diff)
+		}
+	}
+	RenderLocalDiffListing(state, 0, back)
+}
+
+func GetLocalDiffTitle(state *LocalDiff) string {
+	counts := CountDiffs(state.diffs)
+	return fmt.Sprintf("%s vs s3://%s/%s: %d only local, %d only remote, %d changed, %d same",
+		state.root, *state.explorer.bucket.bucket.Name, GetNodePrefix(state.dir),
+		counts[DIFF_ONLY_LOCAL], counts[DIFF_ONLY_REMOTE], counts[DIFF_CHANGED], state.same)
+}
+
+func RenderLocalDiffHelp() *termui.Par {
+	return RenderHelp("<u> upload", "<d> download")
+}
+
+func RenderLocalDiffListing(state *LocalDiff, selection int, back func()) {
+
+	if selection >= len(state.diffs) {
+		selection = len(state.diffs) - 1
+	}
+	if selection < 0 {
+		selection = 0
+	}
+	title := GetLocalDiffTitle(state)
+	var lines []string
+	for _, diff := range state.diffs {
+		lines = append(lines, FormatFileDiff(diff))
+	}
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderLocalDiffHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(state.explorer.deferFunc)
+
+	current := func() {
+		RenderLocalDiffListing(state, selection, back)
+	}
+
+	// Uploads change the bucket, so reload it rather than going back to a
+	// stale tree
+
+	SetBackHandler(func() {
+		if state.modified {
+			state.explorer.deferFunc()
+			LoadBucketExplorer(state.explorer.bucket, state.explorer.showDeleted)
+			return
+		}
+		back()
+	})
+
+	if len(state.diffs) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderLocalDiffHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(state.diffs)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderLocalDiffHelp())
+		}
+	})
+
+	// Enter shows the remote copy in the explorer
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		if state.diffs[selection].Remote != nil && !state.modified {
+			JumpToNode(state.explorer, state.diffs[selection].Remote)
+		}
+	})
+
+	// u uploads the local file over the remote one
+
+	termui.Handle("/sys/kbd/u", func(termui.Event) {
+		diff := state.diffs[selection]
+		if diff.Local == nil {
+			return
+		}
+		ConfirmDiffAction(diff, "Upload", func() {
+			TransferFileDiff(state, selection, true)
+			current()
+		}, current)
+	})
+
+	// d downloads the remote object over the local file
+
+	termui.Handle("/sys/kbd/d", func(termui.Event) {
+		diff := state.diffs[selection]
+		if diff.Object == nil {
+			return
+		}
+		ConfirmDiffAction(diff, "Download", func() {
+			TransferFileDiff(state, selection, false)
+			current()
+		}, current)
+	})
+}
+
+func ConfirmDiffAction(diff FileDiff, action string, onYes func(), onNo func()) {
+
+	// Only ask when something would be overwritten
+
+	if diff.Status != DIFF_CHANGED {
+		onYes()
+		return
+	}
+	RenderConfirmPrompt(action, fmt.Sprintf("%s %s, overwriting the other copy?", action, diff.RelPath), onYes, onNo)
+}
+
+func TransferFileDiff(state *LocalDiff, index int, upload bool) {
+
+	// Upload or download one difference and drop it from the list
+
+	diff := state.diffs[index]
+	bucket := state.explorer.bucket
+	key := GetNodePrefix(state.dir) + diff.RelPath
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+
+	if upload {
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Uploading %s", diff.RelPath)))
+		err = sess.UploadFile(bucket, diff.Local.Path, key)
+		state.modified = state.modified || err == nil
+	} else {
+
+		// Match the local modification time to the object's so the two
+		// compare the same afterwards
+
+		dest := filepath.Join(state.root, filepath.FromSlash(diff.RelPath))
+		termui.Render(CreateDownloadPrompt(dest))
+		err = sess.DownloadObjectVersion(bucket, key, "", dest)
+		if err == nil {
+			modified := aws.TimeValue(diff.Object.LastModified)
+			err = os.Chtimes(dest, modified, modified)
+		}
+	}
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		return
+	}
+	state.diffs = append(state.diffs[:index], state.diffs[index+1:]...)
+	state.same += 1
+}
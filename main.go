@@ -23,6 +23,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"time"
 )
 
 const (
@@ -31,6 +32,9 @@ const (
 	// Dump logs to /dev/null by default
 	DEFAULT_LOG_FILE = os.DevNull
 
+	// Config file name (relative to the user's home directory)
+	DEFAULT_CONFIG_FILE = ".s3explorer.json"
+
 	// FS Options
 	DEFAULT_DIRECTORY_MODE = 0755
 	DEFAULT_FILE_MODE      = 0644
@@ -41,23 +45,148 @@ const (
 	EXIT_FAILED_NO_LOGGER      = 2 // Unable to access log file or /dev/null
 	EXIT_FAILED_AWS_CONNECT    = 3 // Could not connect to AWS S3 API
 	EXIT_FAILED_BUCKET_LISTING = 4 // Could not get initial bucket listing
+	EXIT_FAILED_CONFIG         = 5 // Could not read or parse the config file
+	EXIT_FAILED_USAGE          = 6 // Unknown subcommand or bad arguments
+	EXIT_FAILED_NOT_FOUND      = 7 // Subcommand target bucket, key or file does not exist
+	EXIT_FAILED_COMMAND        = 8 // Subcommand request failed
+	EXIT_FOUND_DIFFERENCES     = 9 // compare found missing, extra or mismatched objects
 
 	// UI Options
 	RIGHT_BUFFER              = 10
 	LOWER_BUFFER              = 10
 	CHECK_TERM_SLEEP_INTERVAL = 1
 	MIN_TERM_HEIGHT_REQUIRED  = 15
+	DELETED_NODE_STYLE        = "fg-black,fg-bold" // renders grey on most terminals
+
+	// Opener Options
+	OPENER_MODE_STDIN       = "stdin" // stream the object to the program's stdin
+	OPENER_MODE_FILE        = "file"  // write the object to a temp file first
+	OPENER_FILE_PLACEHOLDER = "{}"    // replaced with the temp file path
+
+	// Share Options
+	PRESIGN_METHOD_GET     = "GET"
+	PRESIGN_METHOD_PUT     = "PUT"
+	DEFAULT_PRESIGN_EXPIRY = "1h"
+	MAX_PRESIGN_EXPIRY     = 7 * 24 * time.Hour // SigV4 limit
+	DEFAULT_SHARE_LOG_FILE = ".s3explorer_shares.log"
+
+	// Bucket Properties
+	DEFAULT_BACKUP_DIR             = ".s3explorer_backups" // previous documents replaced by the editors
+	DEFAULT_EDITOR                 = "vi"
+	BUCKET_PROPERTY_VERSIONING     = "Versioning"
+	BUCKET_PROPERTY_ENCRYPTION     = "Default Encryption"
+	BUCKET_PROPERTY_PUBLIC_ACCESS  = "Public Access Block"
+	BUCKET_PROPERTY_POLICY         = "Bucket Policy"
+	BUCKET_PROPERTY_ACL            = "ACL"
+	BUCKET_PROPERTY_CORS           = "CORS"
+	BUCKET_PROPERTY_LIFECYCLE      = "Lifecycle Rules"
+	BUCKET_PROPERTY_REPLICATION    = "Replication"
+	BUCKET_PROPERTY_LOGGING        = "Logging"
+	BUCKET_PROPERTY_WEBSITE        = "Website Hosting"
+	BUCKET_PROPERTY_OBJECT_LOCK    = "Object Lock"
+	BUCKET_PROPERTY_REQUESTER_PAYS = "Requester Pays"
+	BUCKET_PROPERTY_TAGS           = "Tags"
 
 	// AWS Options
-	DEFAULT_REGION = "us-west-2" // Used for root-level ListBuckets operations
+	DEFAULT_REGION   = "us-west-2" // Used for root-level ListBuckets operations
+	MAX_DELETE_BATCH = 1000        // DeleteObjects limit per request
+
+	// Storage Class Options
+	MAX_COPY_OBJECT_SIZE = 5 * 1024 * 1024 * 1024 // largest object CopyObject accepts
+	BYTES_PER_GB         = 1024 * 1024 * 1024
+
+	// Pricing Options
+	DEFAULT_PRICE_REGION   = "default" // price table entry used for anything not listed
+	DEFAULT_PRICE_CURRENCY = "USD"
+	ROOT_PREFIX_DISPLAY    = "(top level files)"
+
+	// Disk Usage Options
+	DU_BAR_WIDTH = 20 // characters in the percentage bars
+
+	// Report Options
+	DEFAULT_REPORT_SIZE = 50 // objects listed in the top-N reports
+
+	// Local Diff Options
+	DIFF_ONLY_LOCAL  = "only local"
+	DIFF_ONLY_REMOTE = "only remote"
+	DIFF_CHANGED     = "changed"
+	DIFF_SAME        = "same"
+
+	// Sync Options
+	SYNC_MODE_UPLOAD          = "upload"   // local to remote
+	SYNC_MODE_DOWNLOAD        = "download" // remote to local
+	SYNC_MODE_MIRROR          = "mirror"   // both ways, newest copy wins
+	SYNC_ACTION_UPLOAD        = "upload"
+	SYNC_ACTION_DOWNLOAD      = "download"
+	SYNC_ACTION_DELETE_LOCAL  = "delete_local"
+	SYNC_ACTION_DELETE_REMOTE = "delete_remote"
+	DEFAULT_SYNC_PARALLEL     = 4
+	DEFAULT_SYNC_STATE_FILE   = ".s3explorer_sync.json" // kept in the local directory for mirror mode
+
+	// Prefix Compare Options
+	COMPARE_MISSING          = "missing"    // only in the source
+	COMPARE_EXTRA            = "extra"      // only in the destination
+	COMPARE_MISMATCHED       = "mismatched" // on both sides but different
+	COMPARE_MATCHED          = "matched"
+	DEFAULT_COMPARE_PARALLEL = 8 // metadata requests at once
+
+	// Archive Options
+	DEFAULT_RESTORE_DAYS = 7 // how long restored copies are kept
+
+	// Backend Options
+	BACKEND_AWS            = "aws"
+	BACKEND_MEMORY         = "memory" // made up buckets held in memory, for -demo
+	BACKEND_LOCAL          = "local"  // local directories served as buckets
+	LOCAL_BUCKET_REGION    = "local"  // the region local buckets report
+	BACKEND_GCS            = "gcs"    // Google Cloud Storage through its S3 interoperable API
+	BACKEND_AZURE          = "azure"  // Azure Blob Storage
+	BACKEND_S3             = "s3"     // another AWS account, or an S3 compatible endpoint
+	DEFAULT_GCS_ENDPOINT   = "https://storage.googleapis.com"
+	DEFAULT_AZURE_ENDPOINT = "https://%s.blob.core.windows.net" // filled in with the account
+	AZURE_API_VERSION      = "2020-10-02"
+	AZURE_BLOCK_SIZE       = 8 * 1024 * 1024  // uploads are staged in blocks of this size
+	AZURE_RESPONSE_TIMEOUT = 30 * time.Second // how long Azure may take to start answering
+	PROVIDER_LIST_TIMEOUT  = 20 * time.Second // how long the bucket listing waits for the other accounts
+	REDACTED_DISPLAY       = "<redacted>"     // logged in place of provider credentials
+
+	// Region Cache Options
+	DEFAULT_REGION_CACHE_FILE = ".s3explorer_regions.json"
+	DEFAULT_REGION_CACHE_TTL  = "168h" // how long a looked up region is trusted
+	DEFAULT_REGION_PARALLEL   = 16     // region lookups at once
+	REGION_PENDING_DISPLAY    = "..."  // shown until a bucket's region arrives
+	REGION_EVENT_PATH         = "/usr/regions"
+
+	// Command Output Options
+	OUTPUT_FORMAT_TABLE   = "table" // human readable, the default
+	OUTPUT_FORMAT_JSON    = "json"  // one json array
+	OUTPUT_FORMAT_JSONL   = "jsonl" // one json object per line
+	OUTPUT_FORMAT_CSV     = "csv"   // header row then one row per record
+	DEFAULT_OUTPUT_FORMAT = OUTPUT_FORMAT_TABLE
+	RECORD_TYPE_BUCKET    = "bucket"
+	RECORD_TYPE_PREFIX    = "prefix"
+	RECORD_TYPE_OBJECT    = "object"
 )
 
 var (
 	s3Session         S3Session // initial s3 session
 	localDelimiter    string    // local filesystem path delimiter
 	logFile           string    // log file
+	configFile        string    // config file
+	config            Config    // loaded configuration
 	currentWorkingDir string    // starting local working directory
+	downloadDir       string    // where downloads land, changed from the local browser
 	versionDump       bool      // version dump
+	outputFormat      string    // command output format
+	commandOutput     *OutputWriter
+	demoMode          bool                      // serve made up buckets from memory instead of AWS
+	demoBackend       StorageBackend            // the demo's store, shared by every session
+	localBucketDirs   StringListFlag            // directories to serve as buckets
+	localBackend      LocalBackend              // serves localBucketDirs
+	providerBackends  map[string]StorageBackend // configured non-AWS providers by name
+	profiles          StringListFlag            // credentials profiles, the first replaces the default chain
+	defaultProfile    string                    // the profile of the default account, "" for the default chain
+	groupBuckets      bool                      // bucket listing grouped by account rather than sorted by name
+	regionCache       RegionCache               // bucket regions looked up this run or kept from earlier ones
 )
 
 func dumpVersion() {
@@ -68,12 +197,21 @@ func dumpVersion() {
 	os.Exit(EXIT_USER_REQUESTED)
 }
 
-func init() {
+func Initialize() {
+
+	// Parse the flags, set up logging, load the config and connect. This
+	// runs from main rather than init so the package can be tested.
 
 	// Debug will print a chatty logfile
 
 	flag.StringVar(&logFile, "d", DEFAULT_LOG_FILE, "Path to write debug logs")
+	flag.StringVar(&configFile, "c", DefaultConfigPath(), "Path to config file")
 	flag.BoolVar(&versionDump, "v", false, "Print version and exit")
+	flag.StringVar(&outputFormat, "output", DEFAULT_OUTPUT_FORMAT, "Command output format: table, json, jsonl or csv")
+	flag.BoolVar(&demoMode, "demo", false, "Explore made up buckets held in memory instead of AWS")
+	flag.Var(&localBucketDirs, "local-bucket", "Serve a local directory as a bucket, as dir or name=dir (repeatable)")
+	flag.Var(&profiles, "profile", "Use a credentials profile instead of the default chain, repeat to list several accounts")
+	flag.Usage = PrintUsage
 	flag.Parse()
 
 	if versionDump {
@@ -118,6 +256,52 @@ func init() {
 	} else {
 		log.Printf("Got current working directory: %s\n", currentWorkingDir)
 	}
+	downloadDir = currentWorkingDir
+
+	// Load the config file (or defaults if there isn't one)
+
+	config, err = LoadConfig(configFile)
+	if err != nil {
+		fmt.Printf("Error: Failed to load config file %s: %s\n", configFile, err.Error())
+		os.Exit(EXIT_FAILED_CONFIG)
+	}
+
+	// Regions looked up on earlier runs
+
+	regionCacheTTL, err := time.ParseDuration(config.RegionCacheTTL)
+	if err != nil {
+		fmt.Printf("Error: Bad region_cache_ttl %q: %s\n", config.RegionCacheTTL, err.Error())
+		os.Exit(EXIT_FAILED_CONFIG)
+	}
+	regionCache = LoadRegionCache(config.RegionCache, regionCacheTTL)
+
+	// The first profile is the default account and the others are listed
+	// next to it, before the accounts and providers from the config file
+
+	if len(profiles) > 0 {
+		defaultProfile = profiles[0]
+		config.Providers = append(GetProfileAccounts(profiles[1:]), config.Providers...)
+	}
+	groupBuckets = true
+
+	// Set up the other storage providers from the config file
+
+	providerBackends, err = LoadProviderBackends(config.Providers)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(EXIT_FAILED_CONFIG)
+	}
+
+	// The demo store has to exist before the first session
+
+	if demoMode {
+		demoBackend = NewDemoBackend()
+	}
+	localBackend, err = NewLocalBackend(localBucketDirs)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(EXIT_FAILED_USAGE)
+	}
 
 	// Create an initial s3 session for bucket listing
 	//		ListBuckets returns buckets for all regions
@@ -137,8 +321,13 @@ func init() {
 
 func main() {
 
-	// Start the UI
+	Initialize()
+
+	// Run a subcommand if one was given, otherwise start the UI
 
+	if flag.NArg() > 0 {
+		os.Exit(RunCommand(flag.Args()))
+	}
 	RunUi()
 
 }
@@ -19,21 +19,75 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/gizak/termui"
 )
 
-func RenderBucketListing(buckets []BucketWithDisplay) {
+func RenderBucketListing(buckets []BucketWithDisplay, selection int) {
 
-	// Set the selection pointer
+	// Keep the chosen order with any regions that have arrived, then create
+	// a UI ready list and render
 
-	var selection int
-	selection = 0
-
-	// Create a UI ready list and render
+	buckets = SortBucketListing(buckets, groupBuckets)
+	FillBucketRegions(buckets)
 
 	list := CreateBucketList(buckets, selection)
-	termui.Render(list, RenderHelp())
+	termui.Clear()
+	termui.Render(list, RenderBucketsHelp())
+
+	// Reset anything a previous screen left behind
+
+	termui.ResetHandlers()
+	SetDefaultHandlers(func() { return })
+
+	// Screens opened from here come back to the same selection
+
+	back := func() {
+		RenderBucketListing(buckets, selection)
+	}
+
+	// Regions still being looked up fill in as they arrive
+
+	termui.Handle(REGION_EVENT_PATH, func(termui.Event) {
+		if FillBucketRegions(buckets) {
+			termui.Render(CreateBucketList(buckets, selection), RenderBucketsHelp())
+		}
+	})
+
+	// n creates a new bucket
+
+	termui.Handle("/sys/kbd/n", func(termui.Event) {
+		RenderCreateBucket("", back)
+	})
+
+	// Nothing to select without any buckets
+
+	if len(buckets) == 0 {
+		return
+	}
+
+	// g switches between grouping by account and sorting by name, keeping
+	// the selected bucket selected
+
+	termui.Handle("/sys/kbd/g", func(termui.Event) {
+		chosen := buckets[selection].bucket
+		groupBuckets = !groupBuckets
+		sorted := SortBucketListing(buckets, groupBuckets)
+		for i, bucket := range sorted {
+			if bucket.bucket == chosen {
+				selection = i
+			}
+		}
+		RenderBucketListing(sorted, selection)
+	})
+
+	// The selected bucket, with its region looked up now if it hasn't arrived
+
+	selected := func() BucketWithDisplay {
+		buckets[selection] = GetResolvedBucket(buckets[selection])
+		return buckets[selection]
+	}
 
 	// up goes up
 
@@ -43,7 +97,7 @@ func RenderBucketListing(buckets []BucketWithDisplay) {
 		} else {
 			selection -= 1
 			list := CreateBucketList(buckets, selection)
-			termui.Render(list, RenderHelp())
+			termui.Render(list, RenderBucketsHelp())
 		}
 	})
 
@@ -55,7 +109,7 @@ func RenderBucketListing(buckets []BucketWithDisplay) {
 		} else {
 			selection += 1
 			list := CreateBucketList(buckets, selection)
-			termui.Render(list, RenderHelp())
+			termui.Render(list, RenderBucketsHelp())
 		}
 	})
 
@@ -65,9 +119,44 @@ func RenderBucketListing(buckets []BucketWithDisplay) {
 		termui.ResetHandlers()
 		p := RenderMessage("Loading Bucket", buckets[selection].displayString)
 		termui.Render(p)
-		RenderBucketExplorer(buckets[selection])
+		RenderBucketExplorer(selected())
+	})
+
+	// i shows the bucket's properties
+
+	termui.Handle("/sys/kbd/i", func(termui.Event) {
+		RenderBucketProperties(selected(), back)
+	})
+
+	// $ estimates the bucket's monthly storage cost
+
+	termui.Handle("/sys/kbd/$", func(termui.Event) {
+		RenderBucketCost(selected(), back)
+	})
+
+	// c opens the bucket in the two pane commander
+
+	termui.Handle("/sys/kbd/c", func(termui.Event) {
+		OpenCommander(buckets, selected(), back)
 	})
 
+	// x deletes the bucket
+
+	termui.Handle("/sys/kbd/x", func(termui.Event) {
+		RenderDeleteBucket(selected(), back)
+	})
+
+}
+
+func RenderBucketsHelp() *termui.Par {
+
+	// Help window for the bucket listing
+
+	keys := []string{"<i> properties", "<$> cost", "<c> commander", "<n> new bucket", "<x> delete bucket"}
+	if len(config.Providers) > 0 {
+		keys = append(keys, "<g> group by account/name")
+	}
+	return RenderHelp(keys...)
 }
 
 func ReloadMainBucketsWithError(err error) {
@@ -85,9 +174,22 @@ func ReloadMainBuckets() {
 	termui.ResetHandlers()
 	SetDefaultHandlers(func() { return })
 	termui.Clear()
-	buckets, err := s3Session.GetBucketWithDisplayStrings()
+	buckets, failures, err := s3Session.GetBucketWithDisplayStrings()
 	if err != nil {
 		os.Exit(EXIT_FAILED_BUCKET_LISTING)
 	}
-	RenderBucketListing(buckets)
+	RenderFreshBucketListing(buckets, failures)
+}
+
+func RenderFreshBucketListing(buckets []BucketWithDisplay, failures []string) {
+
+	// Show a new listing, then report every account that couldn't be
+	// listed in a single error and bring the listing back
+
+	RenderBucketListing(buckets, 0)
+	if len(failures) == 0 {
+		return
+	}
+	RenderError(strings.Join(failures, "; "))
+	RenderBucketListing(buckets, 0)
 }
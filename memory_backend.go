@@ -0,0 +1,336 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// A bucket store held in memory, for the demo mode and for exercising the
+// program without AWS. There are no versions: deletes are permanent.
+
+type MemoryObject struct {
+	Data         []byte
+	ContentType  string
+	StorageClass string
+	LastModified time.Time
+}
+
+type MemoryBucket struct {
+	Created time.Time
+	Objects map[string]*MemoryObject
+}
+
+type MemoryBackend struct {
+	mutex   *sync.Mutex
+	region  string
+	buckets map[string]*MemoryBucket
+}
+
+func NewMemoryBackend(region string) MemoryBackend {
+	return MemoryBackend{
+		mutex:   &sync.Mutex{},
+		region:  region,
+		buckets: make(map[string]*MemoryBucket),
+	}
+}
+
+func (b MemoryBackend) Name() string {
+	return BACKEND_MEMORY
+}
+
+func (b MemoryBackend) CreateBucket(name string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.buckets[name]; !ok {
+		b.buckets[name] = &MemoryBucket{Created: time.Now(), Objects: make(map[string]*MemoryObject)}
+	}
+}
+
+func (b MemoryBackend) AddObject(bucket string, key string, object MemoryObject) {
+
+	// Seed an object with chosen attributes, creating the bucket if needed
+
+	b.CreateBucket(bucket)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if object.StorageClass == "" {
+		object.StorageClass = s3.StorageClassStandard
+	}
+	if object.LastModified.IsZero() {
+		object.LastModified = time.Now()
+	}
+	b.buckets[bucket].Objects[key] = &object
+}
+
+func (b MemoryBackend) getBucket(name string) (bucket *MemoryBucket, err error) {
+	bucket, ok := b.buckets[name]
+	if !ok {
+		err = awserr.New(s3.ErrCodeNoSuchBucket, fmt.Sprintf("The specified bucket does not exist: %s", name), nil)
+	}
+	return
+}
+
+func (b MemoryBackend) getObject(bucket string, key string) (object *MemoryObject, err error) {
+	found, err := b.getBucket(bucket)
+	if err != nil {
+		return
+	}
+	object, ok := found.Objects[key]
+	if !ok {
+		err = awserr.New(s3.ErrCodeNoSuchKey, fmt.Sprintf("The specified key does not exist: %s", key), nil)
+	}
+	return
+}
+
+func GetMemoryETag(data []byte) string {
+	sum := md5.Sum(data)
+	return fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:]))
+}
+
+func (b MemoryBackend) ListBuckets() (buckets []*s3.Bucket, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for name, bucket := range b.buckets {
+		buckets = append(buckets, &s3.Bucket{Name: aws.String(name), CreationDate: aws.Time(bucket.Created)})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return *buckets[i].Name < *buckets[j].Name
+	})
+	return
+}
+
+func (b MemoryBackend) GetBucketRegion(bucket string) (region string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, err = b.getBucket(bucket); err != nil {
+		return
+	}
+	return b.region, nil
+}
+
+func (b MemoryBackend) ListObjects(bucket string, prefix string, delimiter string) (objects []*s3.Object, prefixes []string, err error) {
+
+	// Sorted by key like S3, folding keys past the delimiter into prefixes
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	found, err := b.getBucket(bucket)
+	if err != nil {
+		return
+	}
+	var keys []string
+	for key := range found.Objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					prefixes = append(prefixes, common)
+				}
+				continue
+			}
+		}
+		object := found.Objects[key]
+		objects = append(objects, &s3.Object{
+			Key:          aws.String(key),
+			Size:         aws.Int64(int64(len(object.Data))),
+			ETag:         aws.String(GetMemoryETag(object.Data)),
+			LastModified: aws.Time(object.LastModified),
+			StorageClass: aws.String(object.StorageClass),
+		})
+	}
+	return
+}
+
+func (b MemoryBackend) GetObject(bucket string, key string, versionId string) (body io.ReadCloser, contentType string, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	object, err := b.getObject(bucket, key)
+	if err != nil {
+		return
+	}
+	return ioutil.NopCloser(bytes.NewReader(object.Data)), object.ContentType, nil
+}
+
+func (b MemoryBackend) DownloadObject(bucket string, key string, versionId string, dest io.WriterAt) (n int64, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	object, err := b.getObject(bucket, key)
+	if err != nil {
+		return
+	}
+	written, err := dest.WriteAt(object.Data, 0)
+	return int64(written), err
+}
+
+func (b MemoryBackend) PutObject(bucket string, key string, body io.Reader, contentType string) (err error) {
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	found, err := b.getBucket(bucket)
+	if err != nil {
+		return
+	}
+	found.Objects[key] = &MemoryObject{
+		Data:         data,
+		ContentType:  contentType,
+		StorageClass: s3.StorageClassStandard,
+		LastModified: time.Now(),
+	}
+	return
+}
+
+func (b MemoryBackend) CopyObject(srcBucket string, srcKey string, destBucket string, destKey string) (err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	object, err := b.getObject(srcBucket, srcKey)
+	if err != nil {
+		return
+	}
+	found, err := b.getBucket(destBucket)
+	if err != nil {
+		return
+	}
+	copied := *object
+	copied.Data = append([]byte(nil), object.Data...)
+	copied.LastModified = time.Now()
+	found.Objects[destKey] = &copied
+	return
+}
+
+func (b MemoryBackend) DeleteObject(bucket string, key string) (err error) {
+
+	// Deleting a missing key is not an error, as in S3
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	found, err := b.getBucket(bucket)
+	if err != nil {
+		return
+	}
+	delete(found.Objects, key)
+	return
+}
+
+func (b MemoryBackend) DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) (errs []*s3.Error, err error) {
+
+	// Without versions, deleting a version deletes the key
+
+	for _, id := range ids {
+		if err = b.DeleteObject(bucket, aws.StringValue(id.Key)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (b MemoryBackend) HeadObject(bucket string, key string) (out *s3.HeadObjectOutput, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	object, err := b.getObject(bucket, key)
+	if err != nil {
+		err = awserr.New("NotFound", err.Error(), err)
+		return
+	}
+	out = &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(object.Data))),
+		ETag:          aws.String(GetMemoryETag(object.Data)),
+		LastModified:  aws.Time(object.LastModified),
+		StorageClass:  aws.String(object.StorageClass),
+		Metadata:      make(map[string]*string),
+	}
+	if object.ContentType != "" {
+		out.ContentType = aws.String(object.ContentType)
+	}
+	return
+}
+
+func NewDemoBackend() MemoryBackend {
+
+	// A few made up buckets to try the program out with
+
+	log.Println("Seeding demo buckets")
+	backend := NewMemoryBackend(DEFAULT_REGION)
+	now := time.Now()
+	day := 24 * time.Hour
+
+	for i := 0; i < 24; i++ {
+		month := now.AddDate(0, -i, 0)
+		key := fmt.Sprintf("reports/%d/%02d/summary.csv", month.Year(), month.Month())
+		backend.AddObject("demo-analytics", key, MemoryObject{
+			Data:         []byte(strings.Repeat(fmt.Sprintf("%d,%d,%d\n", i, i*7, i*13), 200+i*50)),
+			ContentType:  "text/csv",
+			LastModified: month,
+		})
+	}
+	backend.AddObject("demo-analytics", "README.md", MemoryObject{
+		Data:         []byte("# Analytics\n\nMonthly report summaries.\n"),
+		ContentType:  "text/markdown",
+		LastModified: now.Add(-400 * day),
+	})
+
+	for i := 0; i < 40; i++ {
+		data := bytes.Repeat([]byte{byte(i % 8)}, 50000+(i%8)*25000)
+		class := s3.StorageClassStandard
+		if i < 10 {
+			class = s3.StorageClassGlacier
+		}
+		backend.AddObject("demo-photos", fmt.Sprintf("albums/%d/IMG_%04d.jpg", 2015+i/10, i), MemoryObject{
+			Data:         data,
+			ContentType:  "image/jpeg",
+			StorageClass: class,
+			LastModified: now.Add(-time.Duration(1500-i*35) * day),
+		})
+	}
+
+	for i := 0; i < 30; i++ {
+		at := now.Add(-time.Duration(i) * time.Hour)
+		backend.AddObject("demo-logs", fmt.Sprintf("app/%s/app-%02d.log", at.Format("2006-01-02"), at.Hour()), MemoryObject{
+			Data:         []byte(strings.Repeat(fmt.Sprintf("%s INFO request served\n", at.Format(time.RFC3339)), 100+i)),
+			ContentType:  "text/plain",
+			LastModified: at,
+		})
+	}
+	backend.CreateBucket("demo-empty")
+	return backend
+}
@@ -0,0 +1,161 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func NewTestMemoryBackend(keys ...string) MemoryBackend {
+
+	// A memory backend with one bucket holding each key, its content being the key
+
+	backend := NewMemoryBackend(DEFAULT_REGION)
+	backend.CreateBucket("test")
+	for _, key := range keys {
+		backend.AddObject("test", key, MemoryObject{Data: []byte(key)})
+	}
+	return backend
+}
+
+func GetTestObjectKeys(objects []*s3.Object) (keys []string) {
+	for _, object := range objects {
+		keys = append(keys, aws.StringValue(object.Key))
+	}
+	return
+}
+
+func TestMemoryBackendListObjects(t *testing.T) {
+	backend := NewTestMemoryBackend("a.txt", "logs/1.log", "logs/2.log", "logs/old/3.log", "logs-old/4.log", "photos/x.jpg")
+	tests := []struct {
+		name      string
+		prefix    string
+		delimiter string
+		objects   []string
+		prefixes  []string
+	}{
+		{"everything", "", "", []string{"a.txt", "logs-old/4.log", "logs/1.log", "logs/2.log", "logs/old/3.log", "photos/x.jpg"}, nil},
+		{"top level", "", "/", []string{"a.txt"}, []string{"logs-old/", "logs/", "photos/"}},
+		{"prefix", "logs/", "/", []string{"logs/1.log", "logs/2.log"}, []string{"logs/old/"}},
+		{"prefix recursive", "logs/", "", []string{"logs/1.log", "logs/2.log", "logs/old/3.log"}, nil},
+		{"partial prefix", "logs", "/", nil, []string{"logs-old/", "logs/"}},
+		{"no match", "nothing/", "/", nil, nil},
+	}
+	for _, test := range tests {
+		objects, prefixes, err := backend.ListObjects("test", test.prefix, test.delimiter)
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if keys := GetTestObjectKeys(objects); !reflect.DeepEqual(keys, test.objects) {
+			t.Errorf("%s: got objects %v, want %v", test.name, keys, test.objects)
+		}
+		if !reflect.DeepEqual(prefixes, test.prefixes) {
+			t.Errorf("%s: got prefixes %v, want %v", test.name, prefixes, test.prefixes)
+		}
+	}
+	if _, _, err := backend.ListObjects("missing", "", ""); err == nil {
+		t.Error("listing a missing bucket should fail")
+	}
+}
+
+func TestMemoryBackendPutCopyDelete(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(b MemoryBackend) error
+		keys  []string
+		check string // a key whose content should equal check's value
+		data  string
+	}{
+		{"put", func(b MemoryBackend) error {
+			return b.PutObject("test", "new.txt", bytes.NewReader([]byte("hello")), "text/plain")
+		}, []string{"a", "b", "new.txt"}, "new.txt", "hello"},
+		{"put overwrites", func(b MemoryBackend) error {
+			return b.PutObject("test", "a", bytes.NewReader([]byte("replaced")), "")
+		}, []string{"a", "b"}, "a", "replaced"},
+		{"copy", func(b MemoryBackend) error {
+			return b.CopyObject("test", "a", "test", "dir/a")
+		}, []string{"a", "b", "dir/a"}, "dir/a", "a"},
+		{"copy missing", func(b MemoryBackend) error {
+			if err := b.CopyObject("test", "nope", "test", "c"); err == nil {
+				t.Error("copying a missing key should fail")
+			}
+			return nil
+		}, []string{"a", "b"}, "b", "b"},
+		{"batch delete", func(b MemoryBackend) error {
+			_, err := b.DeleteObjects("test", []*s3.ObjectIdentifier{{Key: aws.String("a")}, {Key: aws.String("missing")}})
+			return err
+		}, []string{"b"}, "b", "b"},
+		{"delete missing", func(b MemoryBackend) error {
+			return b.DeleteObject("test", "missing")
+		}, []string{"a", "b"}, "a", "a"},
+	}
+	for _, test := range tests {
+		backend := NewTestMemoryBackend("a", "b")
+		if err := test.apply(backend); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		objects, _, _ := backend.ListObjects("test", "", "")
+		if keys := GetTestObjectKeys(objects); !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("%s: got keys %v, want %v", test.name, keys, test.keys)
+		}
+		body, _, err := backend.GetObject("test", test.check, "")
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		data, _ := ioutil.ReadAll(body)
+		if string(data) != test.data {
+			t.Errorf("%s: %s holds %q, want %q", test.name, test.check, data, test.data)
+		}
+	}
+}
+
+func TestMemoryBackendHeadObject(t *testing.T) {
+	backend := NewTestMemoryBackend("a.txt")
+	head, err := backend.HeadObject("test", "a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.Int64Value(head.ContentLength) != 5 || aws.StringValue(head.ETag) != GetMemoryETag([]byte("a.txt")) {
+		t.Errorf("unexpected head: %+v", head)
+	}
+	tests := []struct {
+		bucket string
+		key    string
+	}{
+		{"test", "missing.txt"},
+		{"test", "a.tx"},
+		{"missing", "a.txt"},
+	}
+	for _, test := range tests {
+		_, err := backend.HeadObject(test.bucket, test.key)
+		aerr, ok := err.(awserr.Error)
+		if !ok || aerr.Code() != "NotFound" {
+			t.Errorf("head of %s/%s: got %v, want NotFound", test.bucket, test.key, err)
+		}
+		if !IsNotFoundError(err) {
+			t.Errorf("head of %s/%s: %v isn't treated as not found", test.bucket, test.key, err)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+type Opener struct {
+	Extensions   []string `json:"extensions"`
+	ContentTypes []string `json:"content_types"`
+	Command      string   `json:"command"`
+	Mode         string   `json:"mode"`
+}
+
+func DefaultOpeners() []Opener {
+
+	// Pretty print json, page everything else
+
+	return []Opener{
+		{
+			Extensions: []string{".json"},
+			Command:    "jq -C . | ${PAGER:-less -R}",
+			Mode:       OPENER_MODE_STDIN,
+		},
+		{
+			Command: "${PAGER:-less}",
+			Mode:    OPENER_MODE_STDIN,
+		},
+	}
+}
+
+func (o Opener) IsCatchAll() bool {
+
+	// An opener with nothing to match on handles everything
+
+	return len(o.Extensions) == 0 && len(o.ContentTypes) == 0
+}
+
+func MatchOpener(openers []Opener, key string, contentType string) (opener Opener, ok bool) {
+
+	// Extensions win over content types, which win over catch-alls
+
+	lowerKey := strings.ToLower(key)
+	for _, o := range openers {
+		for _, ext := range o.Extensions {
+			if strings.HasSuffix(lowerKey, strings.ToLower(ext)) {
+				return o, true
+			}
+		}
+	}
+	lowerType := strings.ToLower(contentType)
+	for _, o := range openers {
+		for _, ctype := range o.ContentTypes {
+			if lowerType != "" && strings.HasPrefix(lowerType, strings.ToLower(ctype)) {
+				return o, true
+			}
+		}
+	}
+	for _, o := range openers {
+		if o.IsCatchAll() {
+			return o, true
+		}
+	}
+	return
+}
+
+func ShellQuote(s string) string {
+
+	// Quote a string for safe use in a shell command
+
+	if runtime.GOOS == "windows" {
+		return "\"" + s + "\""
+	}
+	return "'" + strings.Replace(s, "'", "'\\''", -1) + "'"
+}
+
+func ShellCommand(command string) *exec.Cmd {
+
+	// Run commands through the system shell so pipes and variables work
+
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+func RunOpener(opener Opener, key string, contentType string, body io.Reader) (status int, err error) {
+
+	log.Printf("Running opener %+v for key: %s\n", opener, key)
+
+	command := opener.Command
+	stdin := io.Reader(os.Stdin)
+
+	if opener.Mode == OPENER_MODE_FILE {
+
+		// Write the object to a temp file keeping its name (and extension)
+
+		var tempDir string
+		tempDir, err = ioutil.TempDir("", "s3explorer")
+		if err != nil {
+			return
+		}
+		defer os.RemoveAll(tempDir)
+
+		dest := filepath.Join(tempDir, path.Base(key))
+		var file *os.File
+		file, err = os.Create(dest)
+		if err != nil {
+			return
+		}
+		_, err = io.Copy(file, body)
+		file.Close()
+		if err != nil {
+			return
+		}
+
+		// Substitute the path for {} or tack it on the end
+
+		if strings.Contains(command, OPENER_FILE_PLACEHOLDER) {
+			command = strings.Replace(command, OPENER_FILE_PLACEHOLDER, ShellQuote(dest), -1)
+		} else {
+			command = command + " " + ShellQuote(dest)
+		}
+
+	} else {
+
+		// Otherwise stream the object straight into the program
+
+		stdin = body
+	}
+
+	cmd := ShellCommand(command)
+	cmd.Stdin = stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"S3EXPLORER_KEY="+key,
+		"S3EXPLORER_CONTENT_TYPE="+contentType,
+	)
+
+	// A non-zero exit is reported, not treated as a failure to run
+
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		status = exitErr.ExitCode()
+		err = nil
+	}
+	log.Printf("Opener exited with status %d\n", status)
+	return
+}
+
+func GetEditor() string {
+
+	// $VISUAL, then $EDITOR, then something that is usually installed
+
+	if editor := os.Getenv("VISUAL"); editor != "" {
+		return editor
+	}
+	if editor := os.Getenv("EDITOR"); editor != "" {
+		return editor
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return DEFAULT_EDITOR
+}
+
+func RunEditor(path string) (err error) {
+
+	// Edit a file with the user's editor attached to the terminal
+
+	editor := GetEditor()
+	log.Printf("Editing %s with %s\n", path, editor)
+	cmd := ShellCommand(editor + " " + ShellQuote(path))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
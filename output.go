@@ -0,0 +1,398 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Anything a headless command reports, in every output format
+
+type Record interface {
+	Columns() []string // csv header
+	Values() []string  // csv row
+	String() string    // table line(s)
+}
+
+type ObjectRecord struct {
+	Type         string `json:"type"` // bucket, prefix or object
+	Bucket       string `json:"bucket"`
+	Key          string `json:"key"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"last_modified"` // RFC 3339, UTC
+	ETag         string `json:"etag"`
+	StorageClass string `json:"storage_class"`
+	Region       string `json:"region"`
+
+	// Table formatting only
+	long  bool
+	human bool
+}
+
+type ObjectDetailRecord struct {
+	ObjectRecord
+	ContentType          string            `json:"content_type"`
+	VersionId            string            `json:"version_id"`
+	ServerSideEncryption string            `json:"server_side_encryption"`
+	Restore              string            `json:"restore"`
+	Metadata             map[string]string `json:"metadata"`
+}
+
+type ActionRecord struct {
+	Action      string `json:"action"` // download, upload, copy or delete
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+type SyncActionRecord struct {
+	Action      string `json:"action"` // upload, download, delete_local or delete_remote
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+	Size        int64  `json:"size"`
+	DryRun      bool   `json:"dry_run"`
+}
+
+type SyncReportRecord struct {
+	Mode          string   `json:"mode"`
+	DryRun        bool     `json:"dry_run"`
+	Uploaded      int      `json:"uploaded"`
+	Downloaded    int      `json:"downloaded"`
+	DeletedLocal  int      `json:"deleted_local"`
+	DeletedRemote int      `json:"deleted_remote"`
+	Unchanged     int      `json:"unchanged"`
+	Failed        int      `json:"failed"`
+	Bytes         int64    `json:"bytes"`
+	Conflicts     []string `json:"conflicts"`
+	Seconds       float64  `json:"seconds"`
+
+	// Table formatting only
+	report SyncReport
+}
+
+type CompareRecord struct {
+	Status      string   `json:"status"` // missing, extra, mismatched or matched
+	Key         string   `json:"key"`    // relative to both prefixes
+	Source      string   `json:"source"`
+	Destination string   `json:"destination"`
+	SourceSize  int64    `json:"source_size"`
+	DestSize    int64    `json:"destination_size"`
+	SourceETag  string   `json:"source_etag"`
+	DestETag    string   `json:"destination_etag"`
+	Reasons     []string `json:"reasons"`
+	Copied      string   `json:"copied"` // "to_destination" or "to_source" once copied
+}
+
+type ErrorRecord struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+type OutputWriter struct {
+	format      string
+	out         io.Writer
+	errOut      io.Writer
+	records     []Record
+	csv         *csv.Writer
+	wroteHeader bool
+}
+
+func GetOutputFormats() []string {
+	return []string{OUTPUT_FORMAT_TABLE, OUTPUT_FORMAT_JSON, OUTPUT_FORMAT_JSONL, OUTPUT_FORMAT_CSV}
+}
+
+func IsOutputFormat(format string) bool {
+	for _, f := range GetOutputFormats() {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+func FormatRecordTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func NewOutputWriter(format string, out io.Writer, errOut io.Writer) *OutputWriter {
+	return &OutputWriter{
+		format: format,
+		out:    out,
+		errOut: errOut,
+		csv:    csv.NewWriter(out),
+	}
+}
+
+func (o *OutputWriter) IsStructured() bool {
+
+	// Errors come out as json objects in the json formats
+
+	return o.format == OUTPUT_FORMAT_JSON || o.format == OUTPUT_FORMAT_JSONL
+}
+
+func (o *OutputWriter) Write(record Record) {
+
+	// Emit a record now, or hold it for the json array
+
+	switch o.format {
+	case OUTPUT_FORMAT_JSON:
+		o.records = append(o.records, record)
+	case OUTPUT_FORMAT_JSONL:
+		data, _ := json.Marshal(record)
+		fmt.Fprintln(o.out, string(data))
+	case OUTPUT_FORMAT_CSV:
+		if !o.wroteHeader {
+			o.csv.Write(record.Columns())
+			o.wroteHeader = true
+		}
+		o.csv.Write(record.Values())
+		o.csv.Flush()
+	default:
+		fmt.Fprintln(o.out, record.String())
+	}
+}
+
+func (o *OutputWriter) WriteError(code string, message string, exitCode int) {
+
+	// Errors go to stderr so stdout stays parseable
+
+	if !o.IsStructured() {
+		fmt.Fprintf(o.errOut, "Error: %s\n", message)
+		return
+	}
+	data, _ := json.Marshal(ErrorRecord{ErrorDetail{code, message, exitCode}})
+	fmt.Fprintln(o.errOut, string(data))
+}
+
+func (o *OutputWriter) Flush() {
+
+	// The json format is a single array of everything written
+
+	if o.format != OUTPUT_FORMAT_JSON {
+		return
+	}
+	records := o.records
+	if records == nil {
+		records = []Record{}
+	}
+	data, _ := json.MarshalIndent(records, "", "  ")
+	fmt.Fprintln(o.out, string(data))
+}
+
+func (r ObjectRecord) Columns() []string {
+	return []string{"type", "bucket", "key", "size", "last_modified", "etag", "storage_class", "region"}
+}
+
+func (r ObjectRecord) Values() []string {
+	return []string{r.Type, r.Bucket, r.Key, strconv.FormatInt(r.Size, 10), r.LastModified, r.ETag, r.StorageClass, r.Region}
+}
+
+func (r ObjectRecord) String() string {
+
+	// Plain names, or ls -l style columns
+
+	name := r.Key
+	if r.Type == RECORD_TYPE_BUCKET {
+		name = r.Bucket
+	}
+	if !r.long {
+		return name
+	}
+	modified := ""
+	if t, err := time.Parse(time.RFC3339, r.LastModified); err == nil {
+		modified = t.Local().Format(listingTimeFormat)
+	}
+	switch r.Type {
+	case RECORD_TYPE_BUCKET:
+		return fmt.Sprintf("%19s  %-14s  %s", modified, r.Region, name)
+	case RECORD_TYPE_PREFIX:
+		return fmt.Sprintf("%19s  %10s  %-19s  %s", "", "PRE", "", name)
+	}
+	size := strconv.FormatInt(r.Size, 10)
+	if r.human {
+		size = ByteFormat(float64(r.Size), 1)
+	}
+	return fmt.Sprintf("%19s  %10s  %-19s  %s", modified, size, r.StorageClass, name)
+}
+
+func (r ObjectDetailRecord) Columns() []string {
+	return append(r.ObjectRecord.Columns(), "content_type", "version_id", "server_side_encryption", "restore", "metadata")
+}
+
+func (r ObjectDetailRecord) Values() []string {
+
+	// Metadata is flattened to name=value pairs
+
+	var pairs []string
+	for _, name := range r.MetadataNames() {
+		pairs = append(pairs, name+"="+r.Metadata[name])
+	}
+	return append(r.ObjectRecord.Values(), r.ContentType, r.VersionId, r.ServerSideEncryption, r.Restore, strings.Join(pairs, ";"))
+}
+
+func (r ObjectDetailRecord) MetadataNames() (names []string) {
+	for name := range r.Metadata {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func (r ObjectDetailRecord) String() string {
+
+	// One "Field: value" line per non-empty field
+
+	if r.Type == RECORD_TYPE_BUCKET {
+		return fmt.Sprintf("%-22s %s\n%-22s %s", "Bucket:", r.Bucket, "Region:", r.Region)
+	}
+	modified := ""
+	if t, err := time.Parse(time.RFC3339, r.LastModified); err == nil {
+		modified = t.Local().Format(listingTimeFormat)
+	}
+	fields := [][2]string{
+		{"Url", FormatS3Url(r.Bucket, r.Key)},
+		{"Size", fmt.Sprintf("%d (%s)", r.Size, ByteFormat(float64(r.Size), 1))},
+		{"Last Modified", modified},
+		{"Content Type", r.ContentType},
+		{"ETag", r.ETag},
+		{"Storage Class", r.StorageClass},
+		{"Region", r.Region},
+		{"Version Id", r.VersionId},
+		{"Server Side Encryption", r.ServerSideEncryption},
+		{"Restore", r.Restore},
+	}
+	for _, name := range r.MetadataNames() {
+		fields = append(fields, [2]string{"Metadata " + name, r.Metadata[name]})
+	}
+	var lines []string
+	for _, field := range fields {
+		if field[1] != "" {
+			lines = append(lines, fmt.Sprintf("%-22s %s", field[0]+":", field[1]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r ActionRecord) Columns() []string {
+	return []string{"action", "source", "destination"}
+}
+
+func (r ActionRecord) Values() []string {
+	return []string{r.Action, r.Source, r.Destination}
+}
+
+func (r ActionRecord) String() string {
+	if r.Destination == "" {
+		return fmt.Sprintf("%s: %s", r.Action, r.Source)
+	}
+	return fmt.Sprintf("%s: %s to %s", r.Action, r.Source, r.Destination)
+}
+
+func (r SyncActionRecord) Columns() []string {
+	return []string{"action", "source", "destination", "reason", "size", "dry_run"}
+}
+
+func (r SyncActionRecord) Values() []string {
+	return []string{r.Action, r.Source, r.Destination, r.Reason, strconv.FormatInt(r.Size, 10), strconv.FormatBool(r.DryRun)}
+}
+
+func (r SyncActionRecord) String() string {
+	line := ActionRecord{r.Action, r.Source, r.Destination}.String()
+	if r.DryRun {
+		line = "(dry run) " + line
+	}
+	return fmt.Sprintf("%s (%s)", line, r.Reason)
+}
+
+func NewSyncReportRecord(report SyncReport) SyncReportRecord {
+	conflicts := report.Conflicts
+	if conflicts == nil {
+		conflicts = []string{}
+	}
+	return SyncReportRecord{
+		Mode:          report.Mode,
+		DryRun:        report.DryRun,
+		Uploaded:      report.Counts[SYNC_ACTION_UPLOAD],
+		Downloaded:    report.Counts[SYNC_ACTION_DOWNLOAD],
+		DeletedLocal:  report.Counts[SYNC_ACTION_DELETE_LOCAL],
+		DeletedRemote: report.Counts[SYNC_ACTION_DELETE_REMOTE],
+		Unchanged:     report.Unchanged,
+		Failed:        len(report.Failures),
+		Bytes:         report.Bytes,
+		Conflicts:     conflicts,
+		Seconds:       report.Duration.Seconds(),
+		report:        report,
+	}
+}
+
+func (r SyncReportRecord) Columns() []string {
+	return []string{"mode", "dry_run", "uploaded", "downloaded", "deleted_local", "deleted_remote", "unchanged", "failed", "bytes", "conflicts", "seconds"}
+}
+
+func (r SyncReportRecord) Values() []string {
+	return []string{
+		r.Mode, strconv.FormatBool(r.DryRun),
+		strconv.Itoa(r.Uploaded), strconv.Itoa(r.Downloaded),
+		strconv.Itoa(r.DeletedLocal), strconv.Itoa(r.DeletedRemote),
+		strconv.Itoa(r.Unchanged), strconv.Itoa(r.Failed),
+		strconv.FormatInt(r.Bytes, 10), strings.Join(r.Conflicts, ";"),
+		strconv.FormatFloat(r.Seconds, 'f', 3, 64),
+	}
+}
+
+func (r SyncReportRecord) String() string {
+	return strings.Join(GetSyncReportLines(r.report), "\n")
+}
+
+func (r CompareRecord) Columns() []string {
+	return []string{"status", "key", "source", "destination", "source_size", "destination_size", "source_etag", "destination_etag", "reasons", "copied"}
+}
+
+func (r CompareRecord) Values() []string {
+	return []string{
+		r.Status, r.Key, r.Source, r.Destination,
+		strconv.FormatInt(r.SourceSize, 10), strconv.FormatInt(r.DestSize, 10),
+		r.SourceETag, r.DestETag, strings.Join(r.Reasons, ";"), r.Copied,
+	}
+}
+
+func (r CompareRecord) String() string {
+	line := fmt.Sprintf("%-12s %s", r.Status, r.Key)
+	if len(r.Reasons) > 0 {
+		line += fmt.Sprintf(" (%s)", strings.Join(r.Reasons, ", "))
+	}
+	if r.Copied != "" {
+		line += " - copied " + strings.Replace(r.Copied, "_", " ", -1)
+	}
+	return line
+}
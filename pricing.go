@@ -0,0 +1,182 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Prices per region. The "default" region is used for any region or
+// storage class a more specific entry leaves out.
+
+type PriceTable struct {
+	Currency string                  `json:"currency"`
+	Regions  map[string]RegionPrices `json:"regions"`
+}
+
+type RegionPrices struct {
+	Storage  map[string]float64 `json:"storage"`  // per GB-month by storage class
+	Requests map[string]float64 `json:"requests"` // per 1000 PUT/COPY requests by destination class
+}
+
+// Billing rules that make small or short lived objects cost more than
+// their size suggests
+
+type StorageClassMinimums struct {
+	Days             int   // minimum storage duration
+	MinimumSize      int64 // smaller objects are billed as this size
+	Overhead         int64 // extra bytes billed per object at the class price
+	StandardOverhead int64 // extra bytes billed per object at the STANDARD price
+}
+
+var storageClassMinimums = map[string]StorageClassMinimums{
+	s3.StorageClassStandardIa:  {Days: 30, MinimumSize: 128 * 1024},
+	s3.StorageClassOnezoneIa:   {Days: 30, MinimumSize: 128 * 1024},
+	s3.StorageClassGlacierIr:   {Days: 90, MinimumSize: 128 * 1024},
+	s3.StorageClassGlacier:     {Days: 90, Overhead: 32 * 1024, StandardOverhead: 8 * 1024},
+	s3.StorageClassDeepArchive: {Days: 180, Overhead: 32 * 1024, StandardOverhead: 8 * 1024},
+}
+
+func DefaultPriceTable() PriceTable {
+
+	// Approximate list prices in USD. They are only used for estimates and
+	// can be replaced or extended in the config file.
+
+	return PriceTable{
+		Currency: DEFAULT_PRICE_CURRENCY,
+		Regions: map[string]RegionPrices{
+			DEFAULT_PRICE_REGION: {
+				Storage: map[string]float64{
+					s3.StorageClassStandard:           0.023,
+					s3.StorageClassReducedRedundancy:  0.024,
+					s3.StorageClassStandardIa:         0.0125,
+					s3.StorageClassOnezoneIa:          0.01,
+					s3.StorageClassIntelligentTiering: 0.023,
+					s3.StorageClassGlacierIr:          0.004,
+					s3.StorageClassGlacier:            0.0036,
+					s3.StorageClassDeepArchive:        0.00099,
+				},
+				Requests: map[string]float64{
+					s3.StorageClassStandard:           0.005,
+					s3.StorageClassReducedRedundancy:  0.005,
+					s3.StorageClassStandardIa:         0.01,
+					s3.StorageClassOnezoneIa:          0.01,
+					s3.StorageClassIntelligentTiering: 0.005,
+					s3.StorageClassGlacierIr:          0.02,
+					s3.StorageClassGlacier:            0.03,
+					s3.StorageClassDeepArchive:        0.05,
+				},
+			},
+			"us-west-1":      {Storage: map[string]float64{s3.StorageClassStandard: 0.026}},
+			"eu-central-1":   {Storage: map[string]float64{s3.StorageClassStandard: 0.0245}},
+			"ap-southeast-2": {Storage: map[string]float64{s3.StorageClassStandard: 0.025}},
+			"sa-east-1":      {Storage: map[string]float64{s3.StorageClassStandard: 0.0405}},
+		},
+	}
+}
+
+func MergePriceTables(base PriceTable, override PriceTable) PriceTable {
+
+	// Prices in override replace those in base class by class
+
+	merged := PriceTable{Currency: base.Currency, Regions: make(map[string]RegionPrices)}
+	if override.Currency != "" {
+		merged.Currency = override.Currency
+	}
+	for _, table := range []PriceTable{base, override} {
+		for region, prices := range table.Regions {
+			current, ok := merged.Regions[region]
+			if !ok {
+				current = RegionPrices{Storage: make(map[string]float64), Requests: make(map[string]float64)}
+			}
+			for class, price := range prices.Storage {
+				current.Storage[class] = price
+			}
+			for class, price := range prices.Requests {
+				current.Requests[class] = price
+			}
+			merged.Regions[region] = current
+		}
+	}
+	return merged
+}
+
+func (t PriceTable) StoragePrice(region string, class string) float64 {
+	if price, ok := t.Regions[region].Storage[class]; ok {
+		return price
+	}
+	return t.Regions[DEFAULT_PRICE_REGION].Storage[class]
+}
+
+func (t PriceTable) RequestPrice(region string, class string) float64 {
+	if price, ok := t.Regions[region].Requests[class]; ok {
+		return price
+	}
+	return t.Regions[DEFAULT_PRICE_REGION].Requests[class]
+}
+
+func GetStorageClasses() []string {
+
+	// Storage classes objects can be moved to, warmest first
+
+	return []string{
+		s3.StorageClassStandard,
+		s3.StorageClassIntelligentTiering,
+		s3.StorageClassStandardIa,
+		s3.StorageClassOnezoneIa,
+		s3.StorageClassGlacierIr,
+		s3.StorageClassGlacier,
+		s3.StorageClassDeepArchive,
+	}
+}
+
+func GetBillableBytes(class string, size int64) (classBytes int64, standardBytes int64) {
+
+	// The bytes an object is billed for in its class, and at the STANDARD
+	// price for the index data the archive classes keep
+
+	minimums := storageClassMinimums[class]
+	classBytes = size + minimums.Overhead
+	if classBytes < minimums.MinimumSize {
+		classBytes = minimums.MinimumSize
+	}
+	return classBytes, minimums.StandardOverhead
+}
+
+func EstimateMonthlyStorageCost(region string, class string, bytes int64) float64 {
+
+	// Estimated cost per month to store bytes in a class
+
+	return float64(bytes) / BYTES_PER_GB * config.Prices.StoragePrice(region, class)
+}
+
+func EstimateObjectStorageCost(region string, class string, size int64) float64 {
+
+	// Estimated cost per month for one object including billing minimums
+
+	classBytes, standardBytes := GetBillableBytes(class, size)
+	return EstimateMonthlyStorageCost(region, class, classBytes) +
+		EstimateMonthlyStorageCost(region, s3.StorageClassStandard, standardBytes)
+}
+
+func EstimateCopyRequestCost(region string, class string, requests int) float64 {
+
+	// Estimated cost for copy requests into a class
+
+	return float64(requests) / 1000 * config.Prices.RequestPrice(region, class)
+}
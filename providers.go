@@ -0,0 +1,368 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Another account or store from the config file. Its buckets are listed
+// next to the default account's with the provider name as a badge. Keys and
+// secrets may refer to environment variables as $NAME.
+
+type ProviderConfig struct {
+	Name      string `json:"name"`       // the badge, unique across providers
+	Type      string `json:"type"`       // s3, gcs or azure
+	Endpoint  string `json:"endpoint"`   // an emulator or S3 compatible service, or empty for the real one
+	AccessKey string `json:"access_key"` // S3 access key or GCS HMAC access id
+	SecretKey string `json:"secret_key"` // S3 secret key, GCS HMAC secret or Azure account key
+	Profile   string `json:"profile"`    // S3 credentials profile, when no keys are given
+	Account   string `json:"account"`    // Azure storage account
+	Project   string `json:"project"`    // GCS project to list buckets from
+	Region    string `json:"region"`     // S3 region to list buckets from, or shown when a location can't be looked up
+}
+
+func RedactProviders(providers []ProviderConfig) (redacted []ProviderConfig) {
+
+	// Copies of the providers that are safe to write to the log
+
+	for _, provider := range providers {
+		for _, field := range []*string{&provider.AccessKey, &provider.SecretKey, &provider.Account} {
+			if *field != "" {
+				*field = REDACTED_DISPLAY
+			}
+		}
+		redacted = append(redacted, provider)
+	}
+	return
+}
+
+func LoadProviderBackends(providers []ProviderConfig) (backends map[string]StorageBackend, err error) {
+
+	// Set up a backend per configured provider. Nothing is contacted yet.
+
+	backends = make(map[string]StorageBackend)
+	for _, provider := range providers {
+		switch provider.Name {
+		case "", BACKEND_AWS, BACKEND_LOCAL, BACKEND_MEMORY:
+			return nil, errors.New(fmt.Sprintf("Provider needs a name other than %q", provider.Name))
+		}
+		if _, ok := backends[provider.Name]; ok {
+			return nil, errors.New(fmt.Sprintf("Provider %s is configured twice", provider.Name))
+		}
+		var backend StorageBackend
+		switch provider.Type {
+		case BACKEND_S3:
+
+			// S3 accounts get a session per bucket region when they are used
+
+			if provider.AccessKey != "" && provider.SecretKey == "" {
+				return nil, errors.New(fmt.Sprintf("Provider %s has an access_key without a secret_key", provider.Name))
+			}
+			log.Printf("Configured s3 account %s\n", provider.Name)
+			backends[provider.Name] = nil
+			continue
+		case BACKEND_GCS:
+			backend, err = NewGCSBackend(provider)
+		case BACKEND_AZURE:
+			backend, err = NewAzureBackend(provider)
+		default:
+			err = errors.New(fmt.Sprintf("Provider %s has unknown type %q (gcs or azure)", provider.Name, provider.Type))
+		}
+		if err != nil {
+			return
+		}
+		log.Printf("Configured %s provider %s\n", provider.Type, provider.Name)
+		backends[provider.Name] = backend
+	}
+	return
+}
+
+func GetProfileAccounts(names []string) (accounts []ProviderConfig) {
+
+	// Credentials profiles given on the command line, named after the profile
+
+	for _, name := range names {
+		accounts = append(accounts, ProviderConfig{Name: name, Type: BACKEND_S3, Profile: name})
+	}
+	return
+}
+
+func GetProviderNames() (names []string) {
+	for _, provider := range config.Providers {
+		names = append(names, provider.Name)
+	}
+	return
+}
+
+func GetProviderConfig(name string) (provider ProviderConfig, err error) {
+	for _, provider = range config.Providers {
+		if provider.Name == name {
+			return
+		}
+	}
+	err = errors.New(fmt.Sprintf("Unknown storage provider: %s", name))
+	return
+}
+
+func GetProviderRegion(provider ProviderConfig) string {
+	if provider.Region != "" {
+		return provider.Region
+	}
+	return DEFAULT_REGION
+}
+
+func InitProviderSession(provider ProviderConfig, region string) (s3session S3Session, err error) {
+
+	// S3 accounts connect with their own credentials in the bucket's region,
+	// the other providers share one backend
+
+	if provider.Type == BACKEND_S3 {
+		log.Printf("Using s3 account %s\n", provider.Name)
+		return InitAccountSession(provider, region)
+	}
+	backend, ok := providerBackends[provider.Name]
+	if !ok {
+		err = errors.New(fmt.Sprintf("Unknown storage provider: %s", provider.Name))
+		return
+	}
+	log.Printf("Using %s provider %s\n", backend.Name(), provider.Name)
+	s3session.Backend = backend
+	return
+}
+
+func GetBucketProvider(bucket BucketWithDisplay) string {
+
+	// Which store a bucket lives in, to tell when a copy crosses stores
+
+	if bucket.provider != "" {
+		return bucket.provider
+	}
+	return BACKEND_AWS
+}
+
+func FormatBucketDisplay(name string, region string, badge string) string {
+	display := fmt.Sprintf("%s (%s)", name, region)
+	if badge != "" {
+		display = fmt.Sprintf("%s [%s]", display, badge)
+	}
+	return display
+}
+
+func GetProviderBuckets() (buckets []BucketWithDisplay, failures []string) {
+
+	// List every configured provider's buckets at once. A provider that
+	// fails or doesn't answer in time is skipped so the others still show,
+	// and its error is returned for the caller to report.
+
+	type accountListing struct {
+		buckets []BucketWithDisplay
+		err     error
+	}
+	results := make([]chan accountListing, len(config.Providers))
+	for i, provider := range config.Providers {
+		results[i] = make(chan accountListing, 1)
+		go func(provider ProviderConfig, result chan accountListing) {
+			listing, err := GetAccountBuckets(provider)
+			result <- accountListing{buckets: listing, err: err}
+		}(provider, results[i])
+	}
+
+	// Collect them in the configured order, sharing one deadline. Once it
+	// has passed, only the listings that already arrived are used.
+
+	deadline := time.NewTimer(PROVIDER_LIST_TIMEOUT)
+	defer deadline.Stop()
+	expired := false
+	for i, provider := range config.Providers {
+		var listing accountListing
+		if !expired {
+			select {
+			case listing = <-results[i]:
+			case <-deadline.C:
+				expired = true
+			}
+		}
+		if expired {
+			select {
+			case listing = <-results[i]:
+			default:
+				listing.err = errors.New("timed out listing buckets")
+			}
+		}
+		if listing.err != nil {
+			log.Printf("%s: %s\n", provider.Name, listing.err.Error())
+			failures = append(failures, fmt.Sprintf("%s: %s", provider.Name, listing.err.Error()))
+			continue
+		}
+		buckets = append(buckets, listing.buckets...)
+	}
+	return
+}
+
+func GetAccountBuckets(provider ProviderConfig) (buckets []BucketWithDisplay, err error) {
+
+	// One provider's buckets, with their regions if they are cached
+
+	sess, err := InitProviderSession(provider, GetProviderRegion(provider))
+	if err != nil {
+		return
+	}
+	listing, err := sess.Backend.ListBuckets()
+	if err != nil {
+		return
+	}
+	for _, bucket := range listing {
+		buckets = append(buckets, GetCachedBucket(BucketWithDisplay{
+			bucket:   bucket,
+			provider: provider.Name,
+			badge:    provider.Name,
+		}, sess.Backend))
+	}
+	return
+}
+
+func GetBucketAccountRank(bucket BucketWithDisplay) int {
+
+	// The default account first, then local buckets, then the providers in
+	// the order they were configured
+
+	switch {
+	case bucket.provider == "":
+		return 0
+	case IsLocalBucket(bucket):
+		return 1
+	}
+	for i, name := range GetProviderNames() {
+		if name == bucket.provider {
+			return i + 2
+		}
+	}
+	return len(config.Providers) + 2
+}
+
+func SortBucketListing(buckets []BucketWithDisplay, byAccount bool) (sorted []BucketWithDisplay) {
+
+	// Group by account keeping each account's own order, or merge every
+	// account's buckets by name
+
+	sorted = append(sorted, buckets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if byAccount {
+			return GetBucketAccountRank(sorted[i]) < GetBucketAccountRank(sorted[j])
+		}
+		return *sorted[i].bucket.Name < *sorted[j].bucket.Name
+	})
+	return
+}
+
+// Google Cloud Storage through its S3 compatible XML API, signed with HMAC
+// keys. Multi-object delete doesn't exist there, and bucket locations come
+// from GetBucketLocation rather than the region header.
+
+type GCSBackend struct {
+	AWSBackend
+	region string
+}
+
+func NewGCSBackend(provider ProviderConfig) (backend GCSBackend, err error) {
+	endpoint := provider.Endpoint
+	if endpoint == "" {
+		endpoint = DEFAULT_GCS_ENDPOINT
+	}
+	accessKey, secretKey := os.ExpandEnv(provider.AccessKey), os.ExpandEnv(provider.SecretKey)
+	if accessKey == "" || secretKey == "" {
+		err = errors.New(fmt.Sprintf("Provider %s needs access_key and secret_key (HMAC keys)", provider.Name))
+		return
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials(accessKey, secretKey, ""),
+		Endpoint:         aws.String(endpoint),
+		Region:           aws.String("auto"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	if err != nil {
+		return
+	}
+	service := s3.New(sess)
+
+	// Without a project GCS lists the buckets of the HMAC key's project
+
+	if provider.Project != "" {
+		project := provider.Project
+		service.Handlers.Build.PushBack(func(r *request.Request) {
+			r.HTTPRequest.Header.Set("x-goog-project-id", project)
+		})
+	}
+	backend = GCSBackend{AWSBackend: AWSBackend{S3Service: service}, region: provider.Region}
+	if backend.region == "" {
+		backend.region = "auto"
+	}
+	return
+}
+
+func (b GCSBackend) Name() string {
+	return BACKEND_GCS
+}
+
+func (b GCSBackend) GetBucketRegion(bucket string) (region string, err error) {
+	out, err := b.S3Service.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err != nil || aws.StringValue(out.LocationConstraint) == "" {
+		return b.region, err
+	}
+	return strings.ToLower(aws.StringValue(out.LocationConstraint)), nil
+}
+
+func (b GCSBackend) DeleteObjects(bucket string, ids []*s3.ObjectIdentifier) (errs []*s3.Error, err error) {
+
+	// One request per key. Deleting a key removes its live object, so a
+	// specific version is refused rather than taking the live one with it.
+
+	for _, id := range ids {
+		if aws.StringValue(id.VersionId) != "" {
+			errs = append(errs, &s3.Error{
+				Key:       id.Key,
+				VersionId: id.VersionId,
+				Code:      aws.String("NotImplemented"),
+				Message:   aws.String("Deleting a specific version isn't supported for GCS buckets"),
+			})
+			continue
+		}
+		if deleteErr := b.DeleteObject(bucket, aws.StringValue(id.Key)); deleteErr != nil {
+			errs = append(errs, &s3.Error{
+				Key:       id.Key,
+				VersionId: id.VersionId,
+				Code:      aws.String("InternalError"),
+				Message:   aws.String(deleteErr.Error()),
+			})
+		}
+	}
+	return
+}
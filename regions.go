@@ -0,0 +1,334 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gizak/termui"
+)
+
+// Bucket regions are looked up in the background and kept on disk for a
+// while, so the bucket listing shows straight away and later launches don't
+// look every bucket up again. Failed lookups are only kept in memory, and
+// are retried the next time the listing is loaded.
+
+type RegionCacheEntry struct {
+	Region   string    `json:"region"`
+	Resolved time.Time `json:"resolved"`
+	failed   bool
+}
+
+type RegionCache struct {
+	path     string
+	ttl      time.Duration
+	mutex    *sync.Mutex
+	entries  map[string]RegionCacheEntry
+	inFlight map[string]bool // keys a worker is looking up
+}
+
+type RegionLookup struct {
+	bucket   BucketWithDisplay
+	key      string         // the bucket's cache key
+	backend  StorageBackend // the bucket's own account
+	fallback string         // a provider's configured region, used when the account doesn't say
+	err      error          // why the account couldn't be set up
+}
+
+func LoadRegionCache(path string, ttl time.Duration) (cache RegionCache) {
+
+	// A missing or unreadable cache just means looking everything up again
+
+	cache = RegionCache{
+		path:     path,
+		ttl:      ttl,
+		mutex:    &sync.Mutex{},
+		entries:  make(map[string]RegionCacheEntry),
+		inFlight: make(map[string]bool),
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("No region cache at %s\n", path)
+		return
+	}
+	var entries map[string]RegionCacheEntry
+	if err = json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Ignoring unreadable region cache %s: %s\n", path, err.Error())
+		return
+	}
+	for key, entry := range entries {
+		if time.Since(entry.Resolved) < ttl {
+			cache.entries[key] = entry
+		}
+	}
+	log.Printf("Loaded %d cached bucket regions from %s\n", len(cache.entries), path)
+	return
+}
+
+func (c RegionCache) Get(key string) (entry RegionCacheEntry, ok bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok = c.entries[key]
+	return
+}
+
+func (c RegionCache) Set(key string, region string, failed bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = RegionCacheEntry{Region: region, Resolved: time.Now(), failed: failed}
+	delete(c.inFlight, key)
+}
+
+func (c RegionCache) StartLookup(key string) bool {
+
+	// Claim a key for a worker unless one already has it. A previous
+	// failure is forgotten so the listing waits for the retry.
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.inFlight[key] {
+		return false
+	}
+	if entry, ok := c.entries[key]; ok && entry.failed {
+		delete(c.entries, key)
+	}
+	c.inFlight[key] = true
+	return true
+}
+
+func (c RegionCache) Save() (err error) {
+
+	// Write the successful lookups next to the cache file then rename it over
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entries := make(map[string]RegionCacheEntry)
+	for key, entry := range c.entries {
+		if !entry.failed {
+			entries[key] = entry
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), DEFAULT_DIRECTORY_MODE); err != nil {
+		return
+	}
+	temp := c.path + ".tmp"
+	if err = ioutil.WriteFile(temp, data, DEFAULT_FILE_MODE); err != nil {
+		return
+	}
+	log.Printf("Saved %d bucket regions to %s\n", len(entries), c.path)
+	return os.Rename(temp, c.path)
+}
+
+func GetRegionCacheKey(bucket BucketWithDisplay) string {
+
+	// Bucket names are only unique within an account
+
+	account := bucket.provider
+	if account == "" {
+		account = GetDefaultAccountName(s3Session.Backend)
+	}
+	return account + "/" + *bucket.bucket.Name
+}
+
+func IsRemoteBackend(backend StorageBackend) bool {
+	switch backend.Name() {
+	case BACKEND_MEMORY, BACKEND_LOCAL:
+		return false
+	}
+	return true
+}
+
+func GetBucketDisplay(bucket BucketWithDisplay) string {
+	region := bucket.region
+	if region == "" {
+		region = REGION_PENDING_DISPLAY
+	}
+	return FormatBucketDisplay(*bucket.bucket.Name, region, bucket.badge)
+}
+
+func GetCachedBucket(bucket BucketWithDisplay, backend StorageBackend) BucketWithDisplay {
+
+	// Fill in a listed bucket's region from the cache, or straight away
+	// from stores that answer without a request. The rest are left empty
+	// for ResolveBucketRegions.
+
+	if bucket.region == "" {
+		if !IsRemoteBackend(backend) {
+			region, err := backend.GetBucketRegion(*bucket.bucket.Name)
+			if err != nil {
+				log.Println(err)
+			}
+			bucket.region = region
+		} else if entry, ok := regionCache.Get(GetRegionCacheKey(bucket)); ok && !entry.failed {
+			bucket.region = entry.Region
+		}
+	}
+	bucket.displayString = GetBucketDisplay(bucket)
+	return bucket
+}
+
+func NewAccountLookup(provider string) (lookup RegionLookup) {
+
+	// Work out which account to ask now, so that lookups never read the
+	// shared session. The result is shared by all of the account's buckets.
+
+	if provider == "" {
+		lookup.backend = s3Session.Backend
+		return
+	}
+	account, err := GetProviderConfig(provider)
+	if err != nil {
+		lookup.err = err
+		return
+	}
+	lookup.fallback = GetProviderRegion(account)
+	sess, err := InitProviderSession(account, lookup.fallback)
+	if err != nil {
+		lookup.err = err
+		return
+	}
+	lookup.backend = sess.Backend
+	return
+}
+
+func NewRegionLookup(bucket BucketWithDisplay) (lookup RegionLookup) {
+	lookup = NewAccountLookup(bucket.provider)
+	lookup.bucket = bucket
+	lookup.key = GetRegionCacheKey(bucket)
+	return
+}
+
+func (l RegionLookup) Resolve() (region string) {
+
+	// Ask the bucket's own account, falling back to a provider's configured
+	// region, and remember the answer. Failures are logged rather than
+	// shown, the bucket just reads as unknown.
+
+	err := l.err
+	if err == nil {
+		log.Printf("Retrieving region for bucket: %s\n", *l.bucket.bucket.Name)
+		region, err = l.backend.GetBucketRegion(*l.bucket.bucket.Name)
+	}
+	if err != nil {
+		log.Printf("Region lookup for %s failed: %s\n", *l.bucket.bucket.Name, err.Error())
+	}
+	if region == "" {
+		region = l.fallback
+	}
+	if region == "" {
+		region = "unknown"
+	}
+	regionCache.Set(l.key, region, err != nil)
+	return
+}
+
+func ResolveBucketRegions(buckets []BucketWithDisplay) {
+
+	// Look the missing regions up on a pool of workers without waiting for
+	// them. Each answer is sent to the bucket listing as an event, and the
+	// cache is saved once they are all in. Everything the workers need is
+	// gathered before they start, with one backend per account.
+
+	var pending []RegionLookup
+	accounts := make(map[string]RegionLookup)
+	for _, bucket := range buckets {
+		key := GetRegionCacheKey(bucket)
+		if bucket.region != "" || !regionCache.StartLookup(key) {
+			continue
+		}
+		lookup, ok := accounts[bucket.provider]
+		if !ok {
+			lookup = NewAccountLookup(bucket.provider)
+			accounts[bucket.provider] = lookup
+		}
+		lookup.bucket = bucket
+		lookup.key = key
+		pending = append(pending, lookup)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	log.Printf("Resolving regions of %d buckets\n", len(pending))
+	go func() {
+		jobs := make(chan RegionLookup)
+		var workers sync.WaitGroup
+		for w := 0; w < DEFAULT_REGION_PARALLEL; w++ {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				for lookup := range jobs {
+					lookup.Resolve()
+					termui.SendCustomEvt(REGION_EVENT_PATH, lookup.key)
+				}
+			}()
+		}
+		for _, lookup := range pending {
+			jobs <- lookup
+		}
+		close(jobs)
+		workers.Wait()
+		log.Printf("Resolved regions of %d buckets\n", len(pending))
+		if err := regionCache.Save(); err != nil {
+			log.Printf("Failed to save the region cache: %s\n", err.Error())
+		}
+	}()
+}
+
+func FillBucketRegions(buckets []BucketWithDisplay) (changed bool) {
+
+	// Copy any regions that have arrived into a listing
+
+	for i := range buckets {
+		if buckets[i].region != "" {
+			continue
+		}
+		if entry, ok := regionCache.Get(GetRegionCacheKey(buckets[i])); ok {
+			buckets[i].region = entry.Region
+			buckets[i].displayString = GetBucketDisplay(buckets[i])
+			changed = true
+		}
+	}
+	return
+}
+
+func GetResolvedBucket(bucket BucketWithDisplay) BucketWithDisplay {
+
+	// A bucket about to be opened needs its region now, whether or not a
+	// worker has got to it yet
+
+	if bucket.region != "" {
+		return bucket
+	}
+	if entry, ok := regionCache.Get(GetRegionCacheKey(bucket)); ok {
+		bucket.region = entry.Region
+	} else {
+		bucket.region = NewRegionLookup(bucket).Resolve()
+	}
+	bucket.displayString = GetBucketDisplay(bucket)
+	return bucket
+}
@@ -0,0 +1,189 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+type AgeBucket struct {
+	Label  string
+	MaxAge time.Duration // 0 for the last, open ended bucket
+	Nodes  []*Node
+	Bytes  int64
+}
+
+func GetReportNodes(dir *Node) (nodes []*Node) {
+
+	// Reports cover every current (not deleted) object under a directory
+
+	for _, node := range GetFileNodes(dir) {
+		if !node.IsDeleted {
+			nodes = append(nodes, node)
+		}
+	}
+	return
+}
+
+func GetNodeSize(node *Node) int64 {
+	return aws.Int64Value(node.S3Object.Size)
+}
+
+func GetNodeModified(node *Node) time.Time {
+	return aws.TimeValue(node.S3Object.LastModified)
+}
+
+func GetTopNodes(nodes []*Node, n int, less func(a *Node, b *Node) bool) []*Node {
+
+	// Sort a copy and keep the first n
+
+	sorted := make([]*Node, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	if n > 0 && len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+func GetLargestNodes(nodes []*Node, n int) []*Node {
+	return GetTopNodes(nodes, n, func(a *Node, b *Node) bool {
+		return GetNodeSize(a) > GetNodeSize(b)
+	})
+}
+
+func GetOldestNodes(nodes []*Node, n int) []*Node {
+	return GetTopNodes(nodes, n, func(a *Node, b *Node) bool {
+		return GetNodeModified(a).Before(GetNodeModified(b))
+	})
+}
+
+func GetNewestNodes(nodes []*Node, n int) []*Node {
+	return GetTopNodes(nodes, n, func(a *Node, b *Node) bool {
+		return GetNodeModified(a).After(GetNodeModified(b))
+	})
+}
+
+func NewAgeBuckets() []*AgeBucket {
+
+	// Age ranges for the histogram, youngest first
+
+	day := 24 * time.Hour
+	return []*AgeBucket{
+		{Label: "< 1 day", MaxAge: day},
+		{Label: "1 - 7 days", MaxAge: 7 * day},
+		{Label: "7 - 30 days", MaxAge: 30 * day},
+		{Label: "30 - 90 days", MaxAge: 90 * day},
+		{Label: "90 days - 1 year", MaxAge: 365 * day},
+		{Label: "1 - 2 years", MaxAge: 2 * 365 * day},
+		{Label: "> 2 years"},
+	}
+}
+
+func GetAgeHistogram(nodes []*Node, now time.Time) []*AgeBucket {
+
+	// Count objects and bytes by how long ago they were last modified
+
+	buckets := NewAgeBuckets()
+	for _, node := range nodes {
+		age := now.Sub(GetNodeModified(node))
+		for _, bucket := range buckets {
+			if bucket.MaxAge == 0 || age < bucket.MaxAge {
+				bucket.Nodes = append(bucket.Nodes, node)
+				bucket.Bytes += GetNodeSize(node)
+				break
+			}
+		}
+	}
+	return buckets
+}
+
+func FormatAgeBucket(bucket *AgeBucket, total int) string {
+	percent := GetUsagePercent(int64(len(bucket.Nodes)), int64(total))
+	return fmt.Sprintf("%-18s %8d objects %10s %5.1f%% %s",
+		bucket.Label, len(bucket.Nodes), ByteFormat(float64(bucket.Bytes), 1), percent, FormatUsageBar(percent, DU_BAR_WIDTH))
+}
+
+func FormatReportNode(node *Node) string {
+	return fmt.Sprintf("%10s  %s  %s",
+		ByteFormat(float64(GetNodeSize(node)), 1), GetNodeModified(node).Local().Format(listingTimeFormat), *node.S3Object.Key)
+}
+
+func GetReportPath(bucket BucketWithDisplay, report string) string {
+
+	// Exports go to the working directory, named after the bucket and report
+
+	name := fmt.Sprintf("s3explorer-%s-%s-%s.csv", *bucket.bucket.Name, report, time.Now().Format("20060102-150405"))
+	return filepath.Join(currentWorkingDir, name)
+}
+
+func WriteCSVFile(path string, rows [][]string) (err error) {
+
+	log.Printf("Writing %d rows to %s\n", len(rows), path)
+	file, err := os.Create(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	err = writer.WriteAll(rows)
+	return
+}
+
+func ExportNodeReport(bucket BucketWithDisplay, report string, nodes []*Node) (path string, err error) {
+
+	// One row per object
+
+	rows := [][]string{{"bucket", "key", "size", "last_modified", "storage_class"}}
+	for _, node := range nodes {
+		rows = append(rows, []string{
+			*bucket.bucket.Name,
+			*node.S3Object.Key,
+			strconv.FormatInt(GetNodeSize(node), 10),
+			FormatRecordTime(GetNodeModified(node)),
+			GetStorageClassDisplay(node.S3Object),
+		})
+	}
+	path = GetReportPath(bucket, report)
+	err = WriteCSVFile(path, rows)
+	return
+}
+
+func ExportAgeHistogram(bucket BucketWithDisplay, buckets []*AgeBucket) (path string, err error) {
+
+	// One row per age range
+
+	rows := [][]string{{"age", "objects", "bytes"}}
+	for _, b := range buckets {
+		rows = append(rows, []string{b.Label, strconv.Itoa(len(b.Nodes)), strconv.FormatInt(b.Bytes, 10)})
+	}
+	path = GetReportPath(bucket, "ages")
+	err = WriteCSVFile(path, rows)
+	return
+}
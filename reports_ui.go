@@ -0,0 +1,179 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gizak/termui"
+)
+
+func RenderReports(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Pick a report for everything under the directory
+
+	nodes := GetReportNodes(dir)
+	if len(nodes) == 0 {
+		RenderError("No objects under this directory")
+		back()
+		return
+	}
+	location := fmt.Sprintf("s3://%s/%s", *explorer.bucket.bucket.Name, GetNodePrefix(dir))
+	reports := []string{"Largest objects", "Oldest objects", "Newest objects", "Age histogram"}
+	RenderChoicePrompt(fmt.Sprintf("Report for %s", location), reports, func(choice int) {
+		if choice == 3 {
+			RenderAgeHistogram(explorer, location, GetAgeHistogram(nodes, time.Now()), len(nodes), 0, back)
+			return
+		}
+		RenderInputPrompt("Number of objects", strconv.Itoa(DEFAULT_REPORT_SIZE), func(input string) {
+			n, err := strconv.Atoi(input)
+			if err != nil || n < 1 {
+				RenderError(fmt.Sprintf("Not a number of objects: %s", input))
+				back()
+				return
+			}
+			title := fmt.Sprintf("%s in %s", reports[choice], location)
+			switch choice {
+			case 0:
+				RenderNodeReport(explorer, title, "largest", GetLargestNodes(nodes, n), 0, back)
+			case 1:
+				RenderNodeReport(explorer, title, "oldest", GetOldestNodes(nodes, n), 0, back)
+			case 2:
+				RenderNodeReport(explorer, title, "newest", GetNewestNodes(nodes, n), 0, back)
+			}
+		}, back)
+	}, back)
+}
+
+func RenderReportHelp() *termui.Par {
+	return RenderHelp("<e> export csv")
+}
+
+func RenderNodeReport(explorer *BucketExplorer, title string, report string, nodes []*Node, selection int, back func()) {
+
+	// A list of objects, enter jumps to one in the explorer
+
+	var lines []string
+	for _, node := range nodes {
+		lines = append(lines, FormatReportNode(node))
+	}
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(explorer.deferFunc)
+	SetBackHandler(back)
+
+	current := func() {
+		RenderNodeReport(explorer, title, report, nodes, selection, back)
+	}
+
+	// e exports the report
+
+	termui.Handle("/sys/kbd/e", func(termui.Event) {
+		path, err := ExportNodeReport(explorer.bucket, report, nodes)
+		current()
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			return
+		}
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Exported to %s", path)))
+	})
+
+	if len(nodes) == 0 {
+		return
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(nodes)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+		}
+	})
+
+	// Enter shows the object in its directory
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		JumpToNode(explorer, nodes[selection])
+	})
+}
+
+func RenderAgeHistogram(explorer *BucketExplorer, location string, buckets []*AgeBucket, total int, selection int, back func()) {
+
+	// Objects by age, enter lists the objects in a range
+
+	var lines []string
+	for _, bucket := range buckets {
+		lines = append(lines, FormatAgeBucket(bucket, total))
+	}
+	title := fmt.Sprintf("Age of %d objects in %s", total, location)
+	termui.Clear()
+	termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+	termui.ResetHandlers()
+	SetDefaultHandlers(explorer.deferFunc)
+	SetBackHandler(back)
+
+	current := func() {
+		RenderAgeHistogram(explorer, location, buckets, total, selection, back)
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+		}
+	})
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(buckets)-1 {
+			selection += 1
+			termui.Render(CreateStringList(title, lines, selection), RenderReportHelp())
+		}
+	})
+
+	// Enter lists the range's objects, oldest first
+
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		bucket := buckets[selection]
+		rangeTitle := fmt.Sprintf("Objects aged %s in %s", bucket.Label, location)
+		RenderNodeReport(explorer, rangeTitle, "age", GetOldestNodes(bucket.Nodes, 0), 0, current)
+	})
+
+	// e exports the histogram
+
+	termui.Handle("/sys/kbd/e", func(termui.Event) {
+		path, err := ExportAgeHistogram(explorer.bucket, buckets)
+		current()
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			return
+		}
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Exported to %s", path)))
+	})
+}
@@ -55,6 +55,7 @@ type Node struct {
 	Children      []*Node
 	Parent        *Node
 	S3Object      *s3.Object
+	IsDeleted     bool // latest version is a delete marker
 }
 
 func CreateMockFs(objects []*s3.Object) (tempDir string, err error) {
@@ -162,6 +163,29 @@ func NewTree(objects []*s3.Object, root string) (result *Node, err error) {
 	return
 }
 
+func BuildBucketTree(objects []*s3.Object) (tree *Node, deferFunc func(), err error) {
+
+	// Create a local mock filesystem for easier indexing and evaluate its
+	// tree. deferFunc removes the mock filesystem.
+
+	mockFsRoot, err := CreateMockFs(objects)
+	if err != nil {
+		return
+	}
+	deferFunc = func() {
+		log.Printf("Cleaning Temp Directory: %s\n", mockFsRoot)
+		err := os.RemoveAll(mockFsRoot)
+		if err != nil {
+			log.Printf("Error cleaning Temp (%s): %s\n", mockFsRoot, err)
+		}
+	}
+	tree, err = NewTree(objects, mockFsRoot)
+	if err != nil {
+		deferFunc()
+	}
+	return
+}
+
 func GetLocalDelimiter() string {
 
 	// If windows return \ else return /
@@ -219,3 +243,106 @@ func GetNodeDirectory(node *Node) (nodes []*Node) {
 	nodes = append(nodes, GetFiles(node)...)
 	return
 }
+
+func GetNodeRoot(node *Node) *Node {
+
+	// Walk up to the root of the tree
+
+	for node.Parent != nil {
+		node = node.Parent
+	}
+	return node
+}
+
+func GetNodePrefix(node *Node) string {
+
+	// Get the s3 key prefix (with trailing delimiter) for a directory node
+
+	root := GetNodeRoot(node)
+	if node == root {
+		return ""
+	}
+	rel := strings.Replace(node.FullPath, root.FullPath+localDelimiter, "", 1)
+	return strings.Replace(rel, localDelimiter, "/", -1) + "/"
+}
+
+func MarkDeletedNodes(node *Node, deleted map[string]bool) (allDeleted bool) {
+
+	// Flag files whose key is deleted, and directories where everything is
+
+	if !node.Info.IsDir {
+		node.IsDeleted = node.S3Object != nil && deleted[*node.S3Object.Key]
+		return node.IsDeleted
+	}
+	allDeleted = len(node.Children) > 0
+	for _, child := range node.Children {
+		if !MarkDeletedNodes(child, deleted) {
+			allDeleted = false
+		}
+	}
+	node.IsDeleted = allDeleted
+	return
+}
+
+func ClearDeletedNodes(node *Node) {
+
+	// Unflag a node and everything under it, then recheck its parents
+
+	node.IsDeleted = false
+	for _, child := range node.Children {
+		ClearDeletedNodes(child)
+	}
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		parent.IsDeleted = false
+	}
+}
+
+func GetFileNodes(node *Node) (nodes []*Node) {
+
+	// Collect every file at or under a node
+
+	if !node.Info.IsDir {
+		if node.S3Object != nil {
+			nodes = append(nodes, node)
+		}
+		return
+	}
+	for _, child := range node.Children {
+		nodes = append(nodes, GetFileNodes(child)...)
+	}
+	return
+}
+
+func GetUniqueFileNodes(targets []*Node) (nodes []*Node) {
+
+	// Collect the files for several targets, counting each only once when
+	// targets overlap (e.g. a marked directory and a file inside it)
+
+	seen := make(map[*Node]bool)
+	for _, target := range targets {
+		for _, node := range GetFileNodes(target) {
+			if !seen[node] {
+				seen[node] = true
+				nodes = append(nodes, node)
+			}
+		}
+	}
+	return
+}
+
+func RemoveNode(node *Node) {
+
+	// Drop a node from its parent's children. The old slice is shared with
+	// ".." nodes and listings already built, so build a new one.
+
+	if node.Parent == nil {
+		return
+	}
+	var children []*Node
+	for _, child := range node.Parent.Children {
+		if child != node {
+			children = append(children, child)
+		}
+	}
+	node.Parent.Children = children
+}
@@ -18,34 +18,39 @@ along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
 package main
 
 import (
-	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
 	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
 
 type S3Session struct {
-	S3Service      *s3.S3
+	S3Service      *s3.S3 // nil unless the backend is AWS
 	ConfigProvider *client.ConfigProvider
 	Buckets        []*s3.Bucket
+	Backend        StorageBackend
 }
 
 type BucketWithDisplay struct {
 	bucket        *s3.Bucket
 	displayString string
 	region        string
+	provider      string // a configured provider's name, BACKEND_LOCAL for local buckets, empty for the default account
+	badge         string // the account shown next to the bucket, if any
 }
 
 func (s S3Session) DownloadObject(bucket BucketWithDisplay, node *Node, dest string) (err error) {
@@ -60,12 +65,12 @@ func (s S3Session) DownloadObject(bucket BucketWithDisplay, node *Node, dest str
 		return
 	}
 
-	// Check if dest already exists
+	return s.DownloadObjectVersion(bucket, *node.S3Object.Key, "", dest)
+}
 
-	if FileExists(dest) {
-		log.Println("Removing pre-existing file")
-		os.Remove(dest)
-	}
+func (s S3Session) DownloadObjectVersion(bucket BucketWithDisplay, key string, versionId string, dest string) (err error) {
+
+	// Download a key (a specific version if versionId is set) to dest
 
 	// Recursively create needed directories
 
@@ -76,34 +81,123 @@ func (s S3Session) DownloadObject(bucket BucketWithDisplay, node *Node, dest str
 		return
 	}
 
-	log.Printf("Creating destination path: %s\n", dest)
+	// Download next to dest and only replace it once the download worked,
+	// so a failure never costs an existing file
+
+	file, err := ioutil.TempFile(filepath.Dir(dest), ".s3explorer-download-")
+	if err != nil {
+		return
+	}
+	log.Printf("Downloading to temp file %s for %s\n", file.Name(), dest)
 
-	// Open a file
+	log.Printf("Downloading from %s backend in region: %s\n", s.Backend.Name(), bucket.region)
 
-	file, err := os.Create(dest)
+	n, err := s.Backend.DownloadObject(*bucket.bucket.Name, key, versionId, file)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), DEFAULT_FILE_MODE)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), dest)
+	}
 	if err != nil {
+		log.Printf("failed to download file: %v\n", err)
+		os.Remove(file.Name())
 		return
 	}
-	defer file.Close()
 
-	log.Printf("Getting downloader for region: %s\n", bucket.region)
+	log.Printf("file downloaded, %d bytes\n", n)
+	return
+}
+
+func (s S3Session) GetObjectReader(bucket BucketWithDisplay, node *Node) (body io.ReadCloser, contentType string, err error) {
+
+	// Open a streaming reader for an object, the caller must close it
+
+	if node.S3Object == nil {
+		err = errors.New(fmt.Sprintf("No s3 object associated with node: %+v\n", node))
+		log.Println(err)
+		return
+	}
+	return s.GetObjectVersionReader(bucket, *node.S3Object.Key, "")
+}
+
+func (s S3Session) GetObjectVersionReader(bucket BucketWithDisplay, key string, versionId string) (body io.ReadCloser, contentType string, err error) {
 
-	// Create a downloader with the s3 client and custom options
+	// Open a streaming reader for a key (a specific version if versionId is set)
 
-	downloader := s3manager.NewDownloaderWithClient(s.S3Service, func(d *s3manager.Downloader) {
-		d.PartSize = 64 * 1024 * 1024 // 64MB per part
-	})
+	log.Printf("Opening stream for object: %s (version: %s)\n", key, versionId)
+	return s.Backend.GetObject(*bucket.bucket.Name, key, versionId)
+}
 
-	n, err := downloader.Download(file, &s3.GetObjectInput{
-		Bucket: bucket.bucket.Name,
-		Key:    node.S3Object.Key,
-	})
+func (s S3Session) PresignObjectUrl(bucket BucketWithDisplay, method string, key string, expiry time.Duration) (url string, err error) {
 
-	if err != nil {
-		log.Printf("failed to download file: %v\n", err)
+	// Create a presigned GET or PUT url for a key
+
+	log.Printf("Presigning %s for %s/%s (expires in %s)\n", method, *bucket.bucket.Name, key, expiry)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	var req *request.Request
+	switch method {
+	case PRESIGN_METHOD_GET:
+		req, _ = s.S3Service.GetObjectRequest(&s3.GetObjectInput{
+			Bucket: bucket.bucket.Name,
+			Key:    aws.String(key),
+		})
+	case PRESIGN_METHOD_PUT:
+		req, _ = s.S3Service.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: bucket.bucket.Name,
+			Key:    aws.String(key),
+		})
+	default:
+		err = errors.New(fmt.Sprintf("Cannot presign method: %s", method))
+		return
 	}
+	url, err = req.Presign(expiry)
+	return
+}
 
-	log.Printf("file downloaded, %d bytes\n", n)
+func (s S3Session) DeleteObjectBatch(bucket BucketWithDisplay, ids []*s3.ObjectIdentifier) (failures []string, err error) {
+	return s.DeleteObjectBatchWithProgress(bucket, ids, nil)
+}
+
+func (s S3Session) DeleteObjectBatchWithProgress(bucket BucketWithDisplay, ids []*s3.ObjectIdentifier, progress func(done int, total int)) (failures []string, err error) {
+
+	// Describe each failed delete for display
+
+	errs, err := s.DeleteObjectBatchErrors(bucket, ids, progress)
+	for _, e := range errs {
+		failures = append(failures, fmt.Sprintf("%s (%s): %s",
+			aws.StringValue(e.Key), aws.StringValue(e.VersionId), aws.StringValue(e.Message)))
+	}
+	return
+}
+
+func (s S3Session) DeleteObjectBatchErrors(bucket BucketWithDisplay, ids []*s3.ObjectIdentifier, progress func(done int, total int)) (errs []*s3.Error, err error) {
+
+	// Delete objects (or specific versions) in batches of the API maximum,
+	// returning the per-key errors of any that failed
+
+	for start := 0; start < len(ids); start += MAX_DELETE_BATCH {
+		if progress != nil {
+			progress(start, len(ids))
+		}
+		end := start + MAX_DELETE_BATCH
+		if end > len(ids) {
+			end = len(ids)
+		}
+		log.Printf("Deleting batch of %d objects from %s\n", end-start, *bucket.bucket.Name)
+		var batch []*s3.Error
+		batch, err = s.Backend.DeleteObjects(*bucket.bucket.Name, ids[start:end])
+		if err != nil {
+			return
+		}
+		errs = append(errs, batch...)
+	}
 	return
 }
 
@@ -112,36 +206,122 @@ func (s S3Session) GetBucketObjects(bucket BucketWithDisplay) (objects []*s3.Obj
 	// For a given bucket, retrieve a list of all its objects
 
 	log.Printf("Listing Objects for Bucket: %s\n", bucket.displayString)
-	err = s.S3Service.ListObjectsV2Pages(&s3.ListObjectsV2Input{
-		Bucket: bucket.bucket.Name,
-	},
-		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-			objects = append(objects, page.Contents...)
-			return true
-		})
+	objects, _, err = s.Backend.ListObjects(*bucket.bucket.Name, "", "")
 	return
 }
 
-func (s S3Session) GetBucketWithDisplayStrings() (bucketStrings []BucketWithDisplay, err error) {
+func (s S3Session) ListPrefix(bucket BucketWithDisplay, prefix string, recursive bool) (objects []*s3.Object, prefixes []string, err error) {
 
-	// Get all buckets, and attach a display string to it
+	// List the objects under a prefix. Unless recursive, stop at the next "/"
+	// and return the sub-prefixes separately.
 
-	buckets, err := s.GetBucketListing()
+	log.Printf("Listing s3://%s/%s (recursive: %v)\n", *bucket.bucket.Name, prefix, recursive)
+	delimiter := "/"
+	if recursive {
+		delimiter = ""
+	}
+	return s.Backend.ListObjects(*bucket.bucket.Name, prefix, delimiter)
+}
+
+func (s S3Session) HeadObject(bucket BucketWithDisplay, key string) (out *s3.HeadObjectOutput, err error) {
+
+	// Fetch an object's metadata without its body
+
+	log.Printf("Head object: s3://%s/%s\n", *bucket.bucket.Name, key)
+	return s.Backend.HeadObject(*bucket.bucket.Name, key)
+}
+
+func (s S3Session) UploadFile(bucket BucketWithDisplay, src string, key string) (err error) {
+
+	// Upload a local file, guessing its content type from the extension
+
+	log.Printf("Uploading %s to s3://%s/%s\n", src, *bucket.bucket.Name, key)
+	file, err := os.Open(src)
 	if err != nil {
 		return
 	}
-	for _, bucket := range buckets {
-		region, err := s.GetBucketRegion(bucket)
-		if err != nil {
-			RenderError(err.Error())
+	defer file.Close()
+
+	return s.Backend.PutObject(*bucket.bucket.Name, key, file, mime.TypeByExtension(filepath.Ext(src)))
+}
+
+func (s S3Session) CopyObject(srcBucket BucketWithDisplay, srcKey string, destBucket BucketWithDisplay, destKey string) (err error) {
+
+	// Server side copy, the session must be in the destination's region
+
+	log.Printf("Copying s3://%s/%s to s3://%s/%s\n", *srcBucket.bucket.Name, srcKey, *destBucket.bucket.Name, destKey)
+	if GetBucketProvider(srcBucket) == GetBucketProvider(destBucket) {
+		return s.Backend.CopyObject(*srcBucket.bucket.Name, srcKey, *destBucket.bucket.Name, destKey)
+	}
+
+	// Between stores the object is streamed through
+
+	from, err := InitBucketSession(srcBucket)
+	if err != nil {
+		return
+	}
+	body, contentType, err := from.Backend.GetObject(*srcBucket.bucket.Name, srcKey, "")
+	if err != nil {
+		return
+	}
+	defer body.Close()
+	return s.Backend.PutObject(*destBucket.bucket.Name, destKey, body, contentType)
+}
+
+func (s S3Session) DeleteObject(bucket BucketWithDisplay, key string) (err error) {
+
+	// Delete the current version of a key (a delete marker on versioned buckets)
+
+	log.Printf("Deleting s3://%s/%s\n", *bucket.bucket.Name, key)
+	return s.Backend.DeleteObject(*bucket.bucket.Name, key)
+}
+
+func (s *S3Session) GetBucketWithDisplayStrings() (bucketStrings []BucketWithDisplay, failures []string, err error) {
+
+	// Refresh the bucket list, and attach a display string to each. With
+	// other accounts or providers configured every bucket carries a badge,
+	// they are listed alongside the default account, and any account that
+	// fails is returned in failures rather than stopping the others.
+
+	var providerBuckets []BucketWithDisplay
+	var providerFailures []string
+	providersDone := make(chan struct{})
+	go func() {
+		providerBuckets, providerFailures = GetProviderBuckets()
+		close(providersDone)
+	}()
+
+	err = s.RefreshBucketListing()
+	if err != nil {
+		if len(config.Providers) == 0 {
+			<-providersDone
+			return
 		}
-		displayBucket := BucketWithDisplay{
-			bucket:        bucket,
-			displayString: fmt.Sprintf("%s (%s)", *bucket.Name, region),
-			region:        region,
+		log.Println(err)
+		failures = append(failures, fmt.Sprintf("%s: %s", GetDefaultAccountName(s.Backend), err.Error()))
+		s.Buckets = nil
+		err = nil
+	}
+	for _, bucket := range s.Buckets {
+		displayBucket := BucketWithDisplay{bucket: bucket}
+		if len(config.Providers) > 0 {
+			displayBucket.badge = GetDefaultAccountName(s.Backend)
 		}
-		bucketStrings = append(bucketStrings, displayBucket)
+		bucketStrings = append(bucketStrings, GetCachedBucket(displayBucket, s.Backend))
 	}
+
+	// Local buckets are listed after the real ones, tagged so that a real
+	// bucket with the same name stays reachable
+
+	bucketStrings = append(bucketStrings, GetLocalBuckets()...)
+
+	<-providersDone
+	bucketStrings = append(bucketStrings, providerBuckets...)
+	failures = append(failures, providerFailures...)
+
+	// Regions that aren't cached fill in while the listing is shown
+
+	ResolveBucketRegions(bucketStrings)
 	return
 }
 
@@ -150,31 +330,21 @@ func (s S3Session) GetBucketRegion(bucket *s3.Bucket) (region string, err error)
 	// Get the region for a bucket
 
 	log.Printf("Retrieving region for bucket: %s\n", *bucket.Name)
-	ctx := context.Background()
-	region, err = s3manager.GetBucketRegionWithClient(ctx, s.S3Service, *bucket.Name)
-	if err != nil {
-		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == "NotFound" {
-			region = "unknown"
-		} else {
-			log.Printf("Unknown Error: %s\n", err.Error())
-		}
-	}
-	return
+	return s.Backend.GetBucketRegion(*bucket.Name)
 }
 
 func (s S3Session) GetBucketListing() (buckets []*s3.Bucket, err error) {
 
 	log.Println("Listing Buckets")
-	resp, err := s.S3Service.ListBuckets(&s3.ListBucketsInput{})
+	buckets, err = s.Backend.ListBuckets()
 	if err != nil {
 		return
 	}
-	buckets = resp.Buckets
 	log.Println("Retrieved bucket list")
 	return
 }
 
-func (s S3Session) RefreshBucketListing() (err error) {
+func (s *S3Session) RefreshBucketListing() (err error) {
 	buckets, err := s.GetBucketListing()
 	log.Println("Refreshed Bucket Listing")
 	if err != nil {
@@ -184,24 +354,129 @@ func (s S3Session) RefreshBucketListing() (err error) {
 	return
 }
 
-func InitSession(region string) (s3session S3Session, err error) {
+func GetDefaultAccountName(backend StorageBackend) string {
+
+	// The default account is badged with its profile if it has one
+
+	if defaultProfile != "" && backend.Name() == BACKEND_AWS {
+		return defaultProfile
+	}
+	return backend.Name()
+}
+
+func IsLocalBucket(bucket BucketWithDisplay) bool {
+	return bucket.provider == BACKEND_LOCAL
+}
+
+func NewLocalBucket(bucket *s3.Bucket) BucketWithDisplay {
+	local := BucketWithDisplay{bucket: bucket, region: LOCAL_BUCKET_REGION, provider: BACKEND_LOCAL}
+	local.displayString = GetBucketDisplay(local)
+	return local
+}
+
+func GetLocalBuckets() (buckets []BucketWithDisplay) {
+	listing, _ := localBackend.ListBuckets()
+	for _, bucket := range listing {
+		buckets = append(buckets, NewLocalBucket(bucket))
+	}
+	return
+}
 
-	creds, err := getCreds()
+func (s S3Session) RequireS3() (err error) {
+
+	// Versions, restores, bucket settings and presigning only exist on S3
+
+	if s.S3Service == nil {
+		err = errors.New(fmt.Sprintf("Not supported by the %s backend", s.Backend.Name()))
+	}
+	return
+}
+
+func InitBucketSession(bucket BucketWithDisplay) (s3session S3Session, err error) {
+
+	// Buckets from other accounts and providers use their own credentials
+
+	if bucket.region == "" {
+		bucket = GetResolvedBucket(bucket)
+	}
+	if bucket.provider == "" || IsLocalBucket(bucket) {
+		return InitSession(bucket.region)
+	}
+	provider, err := GetProviderConfig(bucket.provider)
 	if err != nil {
 		return
 	}
+	return InitProviderSession(provider, bucket.region)
+}
+
+func InitSession(region string) (s3session S3Session, err error) {
+	return InitProfileSession(region, "")
+}
+
+func InitProfileSession(region string, profile string) (s3session S3Session, err error) {
 
-	sess := session.Must(session.NewSession(&aws.Config{
+	// Local buckets are served from their directories whatever the
+	// credentials, and the demo serves everything else from memory
+
+	if region == LOCAL_BUCKET_REGION {
+		s3session.Backend = localBackend
+		log.Println("Using local directory backend")
+		return
+	}
+	if demoMode {
+		s3session.Backend = demoBackend
+		log.Printf("Using demo backend for region: %s\n", region)
+		return
+	}
+
+	// An empty profile is the default account, from -profile or the default chain
+
+	if profile == "" {
+		profile = defaultProfile
+	}
+	return InitAccountSession(ProviderConfig{Profile: profile}, region)
+}
+
+func InitAccountSession(account ProviderConfig, region string) (s3session S3Session, err error) {
+
+	// Keys win over a profile, and an empty profile uses the default credential chain
+
+	var creds *credentials.Credentials
+	if account.AccessKey != "" {
+		creds = credentials.NewStaticCredentials(os.ExpandEnv(account.AccessKey), os.ExpandEnv(account.SecretKey), "")
+	} else {
+		creds, err = getCreds(account.Profile)
+		if err != nil {
+			return
+		}
+	}
+
+	awsConfig := &aws.Config{
 		Credentials: creds,
 		Region:      aws.String(region),
-	}))
+	}
+
+	// S3 compatible services mostly want bucket names in the path
+
+	if account.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(account.Endpoint)
+		awsConfig.S3ForcePathStyle = aws.Bool(true)
+	}
+	sess := session.Must(session.NewSession(awsConfig))
 
 	s3session.S3Service = s3.New(sess)
+	s3session.Backend = AWSBackend{S3Service: s3session.S3Service}
 	log.Printf("Connected to S3 in Region: %s\n", region)
 	return
 }
 
-func getCreds() (creds *credentials.Credentials, err error) {
+func getCreds(profile string) (creds *credentials.Credentials, err error) {
+	if profile != "" {
+		log.Printf("Using credentials profile: %s\n", profile)
+		creds = credentials.NewSharedCredentials("", profile)
+		_, err = creds.Get()
+		return
+	}
 	sess := session.Must(session.NewSession())
 	creds = credentials.NewChainCredentials(
 		[]credentials.Provider{
@@ -0,0 +1,87 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func ParseExpiry(input string) (expiry time.Duration, err error) {
+
+	// Accept go durations (90m, 12h) plus whole days (7d)
+
+	input = strings.TrimSpace(input)
+	if strings.HasSuffix(input, "d") {
+		var days int
+		days, err = strconv.Atoi(strings.TrimSuffix(input, "d"))
+		if err != nil {
+			return
+		}
+		expiry = time.Duration(days) * 24 * time.Hour
+	} else {
+		expiry, err = time.ParseDuration(input)
+		if err != nil {
+			return
+		}
+	}
+	if expiry <= 0 || expiry > MAX_PRESIGN_EXPIRY {
+		err = errors.New(fmt.Sprintf("Expiry must be between 1s and %s", MAX_PRESIGN_EXPIRY))
+	}
+	return
+}
+
+func CopyToClipboard(text string) {
+
+	// Ask the terminal to set the clipboard with an OSC 52 escape.
+	// tmux needs the sequence wrapped in a passthrough.
+
+	log.Println("Copying to clipboard via OSC 52")
+	seq := fmt.Sprintf("\033]52;c;%s\a", base64.StdEncoding.EncodeToString([]byte(text)))
+	if os.Getenv("TMUX") != "" {
+		seq = fmt.Sprintf("\033Ptmux;%s\033\\", strings.Replace(seq, "\033", "\033\033", -1))
+	}
+	os.Stdout.WriteString(seq)
+}
+
+func AppendShareLog(path string, method string, location string, expiry time.Duration, url string) (err error) {
+
+	// Append a tab separated record of a shared url
+
+	log.Printf("Appending to share log: %s\n", path)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, DEFAULT_FILE_MODE)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	now := time.Now()
+	_, err = fmt.Fprintf(file, "%s\t%s\t%s\texpires=%s\t%s\n",
+		now.Format(time.RFC3339),
+		method,
+		location,
+		now.Add(expiry).Format(time.RFC3339),
+		url,
+	)
+	return
+}
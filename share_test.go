@@ -0,0 +1,52 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseExpiry(t *testing.T) {
+	tests := []struct {
+		input  string
+		expiry time.Duration
+		ok     bool
+	}{
+		{"1h", time.Hour, true},
+		{" 90m ", 90 * time.Minute, true},
+		{"7d", 7 * 24 * time.Hour, true},
+		{"1d", 24 * time.Hour, true},
+		{"8d", 0, false},
+		{"169h", 0, false},
+		{"0s", 0, false},
+		{"-1h", 0, false},
+		{"xd", 0, false},
+		{"soon", 0, false},
+	}
+	for _, test := range tests {
+		expiry, err := ParseExpiry(test.input)
+		if (err == nil) != test.ok {
+			t.Errorf("%q: got error %v, want ok %v", test.input, err, test.ok)
+			continue
+		}
+		if test.ok && expiry != test.expiry {
+			t.Errorf("%q: got %s, want %s", test.input, expiry, test.expiry)
+		}
+	}
+}
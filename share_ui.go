@@ -0,0 +1,140 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gizak/termui"
+)
+
+func RenderShareObject(bucket BucketWithDisplay, node *Node, back func()) {
+
+	// Presign a GET for the selected object
+
+	RenderPresignPrompt(bucket, PRESIGN_METHOD_GET, *node.S3Object.Key, back)
+}
+
+func RenderShareUpload(bucket BucketWithDisplay, dir *Node, back func()) {
+
+	// Presign a PUT for a new key under the current prefix
+
+	prefix := GetNodePrefix(dir)
+	label := fmt.Sprintf("Upload key name (under s3://%s/%s)", *bucket.bucket.Name, prefix)
+	RenderInputPrompt(label, "", func(name string) {
+		if name == "" {
+			back()
+			return
+		}
+		RenderPresignPrompt(bucket, PRESIGN_METHOD_PUT, prefix+name, back)
+	}, back)
+}
+
+func RenderPresignPrompt(bucket BucketWithDisplay, method string, key string, back func()) {
+
+	// Ask for an expiry, then presign and show the result
+
+	label := fmt.Sprintf("Expiry for %s s3://%s/%s (e.g. 15m, 12h, 7d)", method, *bucket.bucket.Name, key)
+	RenderInputPrompt(label, DEFAULT_PRESIGN_EXPIRY, func(input string) {
+		expiry, err := ParseExpiry(input)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+
+		sess, err := InitBucketSession(bucket)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+
+		url, err := sess.PresignObjectUrl(bucket, method, key, expiry)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+
+		location := fmt.Sprintf("s3://%s/%s", *bucket.bucket.Name, key)
+		RenderShareDialog(method, location, expiry, url, back)
+	}, back)
+}
+
+func CreateShareDialog(method string, location string, expiry time.Duration, url string, status string) (p *termui.Par) {
+
+	// Create a par with the presigned url, sized to fit it wrapped
+
+	width := termui.TermWidth() - RIGHT_BUFFER
+	text := fmt.Sprintf("%s (expires %s)\n\n%s\n\n%s", location, time.Now().Add(expiry).Format(time.RFC1123), url, status)
+	p = termui.NewPar(text)
+	p.Height = len(url)/(width-2) + 8
+	p.Width = width
+	p.TextFgColor = termui.ColorWhite
+	p.BorderLabel = fmt.Sprintf("Presigned %s URL", method)
+	p.BorderFg = termui.ColorCyan
+	p.Y = 0
+	return
+}
+
+func RenderShareDialog(method string, location string, expiry time.Duration, url string, back func()) {
+
+	// Show a presigned url with actions to copy or log it
+
+	status := ""
+	render := func() {
+		termui.Clear()
+		termui.Render(CreateShareDialog(method, location, expiry, url, status), RenderHelp("<c> copy", "<l> log"))
+	}
+
+	termui.ResetHandlers()
+	render()
+	SetDefaultHandlers(func() { return })
+	SetBackHandler(back)
+
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		back()
+	})
+
+	// c copies to the clipboard
+
+	termui.Handle("/sys/kbd/c", func(termui.Event) {
+		CopyToClipboard(url)
+		status = "Copied to clipboard"
+		render()
+	})
+
+	// l appends to the share log
+
+	termui.Handle("/sys/kbd/l", func(termui.Event) {
+		err := AppendShareLog(config.ShareLog, method, location, expiry, url)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			return
+		}
+		status = fmt.Sprintf("Appended to %s", config.ShareLog)
+		render()
+	})
+}
@@ -0,0 +1,124 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func (s S3Session) ChangeStorageClass(bucket BucketWithDisplay, key string, class string) (err error) {
+
+	// Copy an object over itself in a new storage class, keeping its metadata
+
+	log.Printf("Changing storage class of %s to %s\n", key, class)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	_, err = s.S3Service.CopyObject(&s3.CopyObjectInput{
+		Bucket:            bucket.bucket.Name,
+		Key:               aws.String(key),
+		CopySource:        aws.String(CopySourcePath(*bucket.bucket.Name, key, "")),
+		StorageClass:      aws.String(class),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+	})
+	return
+}
+
+func GetStorageClassPreview(region string, nodes []*Node, class string) (lines []string) {
+
+	// Summarise what a storage class change will move and what it costs
+
+	var total int64
+	var tooLarge, archived, unchanged int
+	var newCost float64
+	sizes := make(map[string]int64)
+	counts := make(map[string]int)
+	costs := make(map[string]float64)
+	for _, node := range nodes {
+		current := GetStorageClassDisplay(node.S3Object)
+		size := aws.Int64Value(node.S3Object.Size)
+		total += size
+		sizes[current] += size
+		counts[current] += 1
+		costs[current] += EstimateObjectStorageCost(region, current, size)
+		newCost += EstimateObjectStorageCost(region, class, size)
+		if current == class {
+			unchanged += 1
+		}
+		if size > MAX_COPY_OBJECT_SIZE {
+			tooLarge += 1
+		}
+		if IsArchivedNode(node) {
+			archived += 1
+		}
+	}
+
+	lines = append(lines,
+		fmt.Sprintf("Change %d objects (%s) to %s", len(nodes), ByteFormat(float64(total), 1), class),
+		"",
+		"Current storage:",
+	)
+
+	var classes []string
+	for current := range sizes {
+		classes = append(classes, current)
+	}
+	sort.Strings(classes)
+	var currentCost float64
+	for _, current := range classes {
+		currentCost += costs[current]
+		lines = append(lines, fmt.Sprintf("  %-20s %6d objects  %10s  %s/month",
+			current, counts[current], ByteFormat(float64(sizes[current]), 1), FormatCost(costs[current])))
+	}
+
+	requests := len(nodes) - unchanged
+	lines = append(lines,
+		"",
+		fmt.Sprintf("Estimated storage: %s/month -> %s/month", FormatCost(currentCost), FormatCost(newCost)),
+		fmt.Sprintf("Estimated copy requests: %s (%d requests)", FormatCost(EstimateCopyRequestCost(region, class, requests)), requests),
+	)
+
+	// Things that will fail or surprise
+
+	var warnings []string
+	if unchanged > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d objects are already %s and will be skipped", unchanged, class))
+	}
+	if tooLarge > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d objects are over 5GB and cannot be changed with CopyObject", tooLarge))
+	}
+	if archived > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d objects are archived and must be restored first", archived))
+	}
+	if minimums, ok := storageClassMinimums[class]; ok {
+		warnings = append(warnings, fmt.Sprintf("%s has a %d day minimum storage duration", class, minimums.Days))
+	}
+	if len(warnings) > 0 {
+		lines = append(lines, "", "Warnings:")
+		for _, warning := range warnings {
+			lines = append(lines, "  "+warning)
+		}
+	}
+	lines = append(lines, "", "Prices are estimates from the price table (see the prices config). Press <y> to apply.")
+	return
+}
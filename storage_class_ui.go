@@ -0,0 +1,99 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/gizak/termui"
+)
+
+func RenderChangeStorageClass(explorer *BucketExplorer, targets []*Node, back func()) {
+
+	// Pick a storage class, preview the change, then apply it
+
+	nodes := GetUniqueFileNodes(targets)
+	if len(nodes) == 0 {
+		RenderError("No objects selected")
+		return
+	}
+
+	classes := GetStorageClasses()
+	label := fmt.Sprintf("New storage class for %d objects", len(nodes))
+	RenderChoicePrompt(label, classes, func(choice int) {
+		class := classes[choice]
+		RenderTextViewer("Storage Class Change", GetStorageClassPreview(explorer.bucket.region, nodes, class), back, "<y> apply")
+		termui.Handle("/sys/kbd/y", func(termui.Event) {
+			ApplyStorageClass(explorer, nodes, class, back)
+		})
+	}, back)
+}
+
+func ApplyStorageClass(explorer *BucketExplorer, nodes []*Node, class string, back func()) {
+
+	// Copy each object in place with progress, then report failures
+
+	sess, err := InitBucketSession(explorer.bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	label := fmt.Sprintf("Changing storage class to %s", class)
+	termui.Clear()
+	var failures []string
+	changed := 0
+	for i, node := range nodes {
+		termui.Render(CreateProgressGauge(label, i, len(nodes)))
+		key := *node.S3Object.Key
+		if GetStorageClassDisplay(node.S3Object) == class {
+			continue
+		}
+		if aws.Int64Value(node.S3Object.Size) > MAX_COPY_OBJECT_SIZE {
+			failures = append(failures, fmt.Sprintf("%s: larger than 5GB", key))
+			continue
+		}
+		err := sess.ChangeStorageClass(explorer.bucket, key, class)
+		if err != nil {
+			log.Println(err)
+			failures = append(failures, fmt.Sprintf("%s: %s", key, err.Error()))
+			continue
+		}
+		node.S3Object.StorageClass = aws.String(class)
+		changed += 1
+	}
+	termui.Render(CreateProgressGauge(label, len(nodes), len(nodes)))
+
+	// Marks have been acted on
+
+	explorer.marked = make(map[*Node]bool)
+
+	if len(failures) > 0 {
+		lines := append([]string{
+			fmt.Sprintf("Changed %d objects, %d failed:", changed, len(failures)),
+		}, failures...)
+		RenderTextViewer("Storage Class Failures", lines, back)
+		return
+	}
+	back()
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Changed %d objects to %s", changed, class)))
+}
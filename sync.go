@@ -0,0 +1,566 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type SyncOptions struct {
+	Mode     string // one of the SYNC_MODE_* constants
+	Delete   bool   // propagate deletes to the other side
+	Include  []string
+	Exclude  []string
+	Checksum bool
+	DryRun   bool
+	Parallel int
+}
+
+type SyncAction struct {
+	Action    string // one of the SYNC_ACTION_* constants
+	RelPath   string
+	LocalPath string
+	Key       string
+	Size      int64
+	Reason    string
+	Modified  time.Time // the object's time, kept on downloads
+}
+
+type SyncReport struct {
+	Mode      string
+	DryRun    bool
+	Counts    map[string]int // completed (or planned) actions by SYNC_ACTION_*
+	Bytes     int64
+	Unchanged int
+	Conflicts []string
+	Failures  []string
+	Duration  time.Duration
+}
+
+// What both sides looked like after the last mirror, so a file missing on
+// one side can be told apart from a new file on the other
+
+type SyncStateEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mod_time"` // local modification time, unix seconds
+	ETag    string `json:"etag"`
+}
+
+type SyncState map[string]map[string]SyncStateEntry // location -> relative path -> entry
+
+type SyncFilter struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func GetSyncModes() []string {
+	return []string{SYNC_MODE_UPLOAD, SYNC_MODE_DOWNLOAD, SYNC_MODE_MIRROR}
+}
+
+func IsSyncMode(mode string) bool {
+	for _, m := range GetSyncModes() {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func GlobToRegexp(pattern string) (*regexp.Regexp, error) {
+
+	// "**" matches across directories, "*" and "?" stay within one. A
+	// pattern without a "/" is matched against the file name at any depth.
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if !strings.Contains(pattern, "/") {
+		expr.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*' && i+1 < len(pattern) && pattern[i+1] == '*':
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func NewSyncFilter(include []string, exclude []string) (filter SyncFilter, err error) {
+
+	// Compile the include and exclude globs
+
+	for _, pattern := range include {
+		re, err := GlobToRegexp(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("Bad include pattern %s: %s", pattern, err)
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := GlobToRegexp(pattern)
+		if err != nil {
+			return filter, fmt.Errorf("Bad exclude pattern %s: %s", pattern, err)
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return
+}
+
+func (f SyncFilter) Allows(rel string) bool {
+
+	// Excludes win over includes, and no includes means everything. The
+	// state file is never synced.
+
+	if rel == DEFAULT_SYNC_STATE_FILE {
+		return false
+	}
+	for _, re := range f.exclude {
+		if re.MatchString(rel) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, re := range f.include {
+		if re.MatchString(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func LoadSyncState(root string) (state SyncState, err error) {
+
+	// A missing state file just means nothing has been mirrored yet
+
+	state = make(SyncState)
+	data, err := ioutil.ReadFile(filepath.Join(root, DEFAULT_SYNC_STATE_FILE))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &state)
+	return
+}
+
+func SaveSyncState(root string, state SyncState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(root, DEFAULT_SYNC_STATE_FILE), data, DEFAULT_FILE_MODE)
+}
+
+func GetSyncStateEntries(diffs []FileDiff) map[string]SyncStateEntry {
+
+	// Record every file that is the same on both sides
+
+	entries := make(map[string]SyncStateEntry)
+	for _, diff := range diffs {
+		if diff.Local == nil || diff.Object == nil || diff.Local.Size != aws.Int64Value(diff.Object.Size) {
+			continue
+		}
+		entries[diff.RelPath] = SyncStateEntry{
+			Size:    diff.Local.Size,
+			ModTime: diff.Local.ModTime.Unix(),
+			ETag:    GetObjectETag(diff.Object),
+		}
+	}
+	return entries
+}
+
+func GetObjectETag(object *s3.Object) string {
+	return strings.Trim(aws.StringValue(object.ETag), "\"")
+}
+
+func NewSyncAction(action string, diff FileDiff, root string, prefix string, reason string) SyncAction {
+
+	// Fill in both locations of a planned action
+
+	planned := SyncAction{
+		Action:    action,
+		RelPath:   diff.RelPath,
+		LocalPath: filepath.Join(root, filepath.FromSlash(diff.RelPath)),
+		Key:       prefix + diff.RelPath,
+		Reason:    reason,
+	}
+	if diff.Local != nil {
+		planned.Size = diff.Local.Size
+	}
+	if diff.Object != nil {
+		if action != SYNC_ACTION_UPLOAD {
+			planned.Size = aws.Int64Value(diff.Object.Size)
+		}
+		planned.Modified = aws.TimeValue(diff.Object.LastModified)
+	}
+	return planned
+}
+
+func PlanSync(diffs []FileDiff, root string, prefix string, options SyncOptions, entries map[string]SyncStateEntry) (actions []SyncAction, unchanged int, conflicts []string) {
+
+	// Work out what to transfer or delete for each difference
+
+	for _, diff := range diffs {
+		add := func(action string, reason string) {
+			actions = append(actions, NewSyncAction(action, diff, root, prefix, reason))
+		}
+		switch options.Mode {
+
+		case SYNC_MODE_UPLOAD:
+			switch {
+			case diff.Status == DIFF_ONLY_LOCAL:
+				add(SYNC_ACTION_UPLOAD, "new")
+			case diff.Status == DIFF_CHANGED:
+				add(SYNC_ACTION_UPLOAD, diff.Reason)
+			case diff.Status == DIFF_ONLY_REMOTE && options.Delete:
+				add(SYNC_ACTION_DELETE_REMOTE, "not local")
+			case diff.Status == DIFF_SAME:
+				unchanged += 1
+			}
+
+		case SYNC_MODE_DOWNLOAD:
+
+			// Downloads keep the object's time, so a newer object means it
+			// was replaced since
+
+			switch {
+			case diff.Status == DIFF_ONLY_REMOTE:
+				add(SYNC_ACTION_DOWNLOAD, "new")
+			case diff.Status == DIFF_CHANGED:
+				add(SYNC_ACTION_DOWNLOAD, diff.Reason)
+			case diff.Status == DIFF_SAME && diff.RemoteNewer && !options.Checksum:
+				add(SYNC_ACTION_DOWNLOAD, "remote newer")
+			case diff.Status == DIFF_ONLY_LOCAL && options.Delete:
+				add(SYNC_ACTION_DELETE_LOCAL, "not remote")
+			case diff.Status == DIFF_SAME:
+				unchanged += 1
+			}
+
+		case SYNC_MODE_MIRROR:
+			entry, synced := entries[diff.RelPath]
+			switch diff.Status {
+
+			case DIFF_ONLY_LOCAL:
+
+				// Gone from the remote since the last mirror, unless the
+				// local copy has changed since
+
+				localChanged := diff.Local.Size != entry.Size || diff.Local.ModTime.Unix() != entry.ModTime
+				switch {
+				case !synced:
+					add(SYNC_ACTION_UPLOAD, "new")
+				case options.Delete && !localChanged:
+					add(SYNC_ACTION_DELETE_LOCAL, "deleted remotely")
+				case options.Delete:
+					add(SYNC_ACTION_UPLOAD, "deleted remotely but changed locally")
+					conflicts = append(conflicts, diff.RelPath)
+				default:
+					add(SYNC_ACTION_UPLOAD, "missing remotely")
+				}
+
+			case DIFF_ONLY_REMOTE:
+				remoteChanged := GetObjectETag(diff.Object) != entry.ETag
+				switch {
+				case !synced:
+					add(SYNC_ACTION_DOWNLOAD, "new")
+				case options.Delete && !remoteChanged:
+					add(SYNC_ACTION_DELETE_REMOTE, "deleted locally")
+				case options.Delete:
+					add(SYNC_ACTION_DOWNLOAD, "deleted locally but changed remotely")
+					conflicts = append(conflicts, diff.RelPath)
+				default:
+					add(SYNC_ACTION_DOWNLOAD, "missing locally")
+				}
+
+			default:
+				localChanged := diff.Local.Size != entry.Size || diff.Local.ModTime.Unix() != entry.ModTime
+				remoteChanged := GetObjectETag(diff.Object) != entry.ETag
+				localNewer := diff.Local.ModTime.After(aws.TimeValue(diff.Object.LastModified))
+				switch {
+				case synced && !localChanged && !remoteChanged:
+					unchanged += 1
+				case synced && localChanged && !remoteChanged:
+					add(SYNC_ACTION_UPLOAD, "changed locally")
+				case synced && remoteChanged && !localChanged:
+					add(SYNC_ACTION_DOWNLOAD, "changed remotely")
+				case diff.Status == DIFF_SAME:
+					unchanged += 1
+				default:
+
+					// Changed on both sides (or never mirrored), the newer
+					// copy wins
+
+					if synced {
+						conflicts = append(conflicts, diff.RelPath)
+					}
+					if localNewer {
+						add(SYNC_ACTION_UPLOAD, "local newer")
+					} else {
+						add(SYNC_ACTION_DOWNLOAD, "remote newer")
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func (s S3Session) ApplySyncAction(bucket BucketWithDisplay, action SyncAction) (err error) {
+
+	// Carry out one planned action
+
+	switch action.Action {
+	case SYNC_ACTION_UPLOAD:
+		err = s.UploadFile(bucket, action.LocalPath, action.Key)
+	case SYNC_ACTION_DOWNLOAD:
+
+		// Keep the object's time so the two compare the same afterwards
+
+		err = s.DownloadObjectVersion(bucket, action.Key, "", action.LocalPath)
+		if err != nil {
+			return
+		}
+		err = os.Chtimes(action.LocalPath, action.Modified, action.Modified)
+	case SYNC_ACTION_DELETE_LOCAL:
+		log.Printf("Deleting local file %s\n", action.LocalPath)
+		err = os.Remove(action.LocalPath)
+	case SYNC_ACTION_DELETE_REMOTE:
+		err = s.DeleteObject(bucket, action.Key)
+	}
+	return
+}
+
+func (s S3Session) RunSync(bucket BucketWithDisplay, root string, prefix string, options SyncOptions, progress func(action SyncAction, err error, done int, total int)) (report SyncReport, err error) {
+
+	// Compare, plan and run a sync between a local directory and a prefix.
+	// Progress is called once per action (as planned on a dry run), never
+	// from two workers at once.
+
+	start := time.Now()
+	report = SyncReport{Mode: options.Mode, DryRun: options.DryRun, Counts: make(map[string]int)}
+	if !IsSyncMode(options.Mode) {
+		return report, fmt.Errorf("Unknown sync mode %s", options.Mode)
+	}
+	filter, err := NewSyncFilter(options.Include, options.Exclude)
+	if err != nil {
+		return
+	}
+	if info, statErr := os.Stat(root); statErr != nil || !info.IsDir() {
+
+		// Downloads may create the directory
+
+		if options.Mode != SYNC_MODE_DOWNLOAD || options.DryRun || statErr == nil {
+			return report, fmt.Errorf("Not a directory: %s", root)
+		}
+		if err = os.MkdirAll(root, DEFAULT_DIRECTORY_MODE); err != nil {
+			return
+		}
+	}
+	location := FormatS3Url(*bucket.bucket.Name, prefix)
+	log.Printf("Syncing %s with %s: %+v\n", root, location, options)
+
+	// Compare both sides
+
+	objects, _, err := s.ListPrefix(bucket, prefix, true)
+	if err != nil {
+		return
+	}
+	diffs, err := CompareLocalObjects(root, prefix, objects, filter.Allows, options.Checksum, nil)
+	if err != nil {
+		return
+	}
+	state, err := LoadSyncState(root)
+	if err != nil {
+		return report, fmt.Errorf("Could not read %s: %s", DEFAULT_SYNC_STATE_FILE, err)
+	}
+	actions, unchanged, conflicts := PlanSync(diffs, root, prefix, options, state[location])
+	report.Unchanged = unchanged
+	report.Conflicts = conflicts
+	log.Printf("Planned %d sync actions, %d files unchanged\n", len(actions), unchanged)
+
+	if options.DryRun {
+		for i, action := range actions {
+			report.Counts[action.Action] += 1
+			report.Bytes += GetSyncActionBytes(action)
+			if progress != nil {
+				progress(action, nil, i+1, len(actions))
+			}
+		}
+		report.Duration = time.Since(start)
+		return
+	}
+
+	// Run the actions on a pool of workers
+
+	parallel := options.Parallel
+	if parallel < 1 {
+		parallel = DEFAULT_SYNC_PARALLEL
+	}
+	jobs := make(chan SyncAction)
+	var lock sync.Mutex
+	var workers sync.WaitGroup
+	done := 0
+	for i := 0; i < parallel; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for action := range jobs {
+				err := s.ApplySyncAction(bucket, action)
+				lock.Lock()
+				done += 1
+				if err != nil {
+					log.Printf("Sync %s of %s failed: %s\n", action.Action, action.RelPath, err)
+					report.Failures = append(report.Failures, fmt.Sprintf("%s %s: %s", action.Action, action.RelPath, err))
+				} else {
+					report.Counts[action.Action] += 1
+					report.Bytes += GetSyncActionBytes(action)
+				}
+				if progress != nil {
+					progress(action, err, done, len(actions))
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	for _, action := range actions {
+		jobs <- action
+	}
+	close(jobs)
+	workers.Wait()
+
+	// Mirrors remember what both sides now hold
+
+	if options.Mode == SYNC_MODE_MIRROR {
+		err = s.SaveMirrorState(bucket, root, prefix, filter, state)
+	}
+	report.Duration = time.Since(start)
+	return
+}
+
+func (s S3Session) SaveMirrorState(bucket BucketWithDisplay, root string, prefix string, filter SyncFilter, state SyncState) (err error) {
+
+	// Compare both sides again and record the files that match
+
+	objects, _, err := s.ListPrefix(bucket, prefix, true)
+	if err != nil {
+		return
+	}
+	diffs, err := CompareLocalObjects(root, prefix, objects, filter.Allows, false, nil)
+	if err != nil {
+		return
+	}
+	state[FormatS3Url(*bucket.bucket.Name, prefix)] = GetSyncStateEntries(diffs)
+	return SaveSyncState(root, state)
+}
+
+func GetSyncActionBytes(action SyncAction) int64 {
+
+	// Only transfers move data
+
+	if action.Action == SYNC_ACTION_UPLOAD || action.Action == SYNC_ACTION_DOWNLOAD {
+		return action.Size
+	}
+	return 0
+}
+
+func GetSyncPrefix(key string) string {
+
+	// Sync always works on a whole prefix
+
+	if key != "" && !strings.HasSuffix(key, "/") {
+		key += "/"
+	}
+	return key
+}
+
+func FormatSyncAction(action SyncAction) string {
+	size := ""
+	if GetSyncActionBytes(action) > 0 {
+		size = fmt.Sprintf(" (%s)", ByteFormat(float64(action.Size), 1))
+	}
+	return fmt.Sprintf("%-14s %s%s - %s", action.Action, action.RelPath, size, action.Reason)
+}
+
+func GetSyncReportLines(report SyncReport) (lines []string) {
+
+	// Summary shown at the end of a sync. Failures are listed by the caller.
+
+	verb := "Completed"
+	if report.DryRun {
+		verb = "Planned (dry run)"
+	}
+	lines = append(lines,
+		fmt.Sprintf("Mode:           %s", report.Mode),
+		fmt.Sprintf("%s:", verb),
+	)
+	for _, action := range []string{SYNC_ACTION_UPLOAD, SYNC_ACTION_DOWNLOAD, SYNC_ACTION_DELETE_LOCAL, SYNC_ACTION_DELETE_REMOTE} {
+		lines = append(lines, fmt.Sprintf("  %-14s %d", action, report.Counts[action]))
+	}
+	lines = append(lines,
+		fmt.Sprintf("Transferred:    %s", ByteFormat(float64(report.Bytes), 1)),
+		fmt.Sprintf("Unchanged:      %d", report.Unchanged),
+		fmt.Sprintf("Failed:         %d", len(report.Failures)),
+		fmt.Sprintf("Duration:       %s", report.Duration.Round(time.Millisecond)),
+	)
+	if len(report.Conflicts) > 0 {
+		lines = append(lines, "", "Changed on both sides (the newer copy was kept):")
+		for _, conflict := range report.Conflicts {
+			lines = append(lines, "  "+conflict)
+		}
+	}
+	return
+}
+
+func GetSyncActionLocations(bucket BucketWithDisplay, action SyncAction) (source string, destination string) {
+
+	// Where an action reads from and writes to (deletes have no destination)
+
+	remote := FormatS3Url(*bucket.bucket.Name, action.Key)
+	switch action.Action {
+	case SYNC_ACTION_UPLOAD:
+		return action.LocalPath, remote
+	case SYNC_ACTION_DOWNLOAD:
+		return remote, action.LocalPath
+	case SYNC_ACTION_DELETE_LOCAL:
+		return action.LocalPath, ""
+	}
+	return remote, ""
+}
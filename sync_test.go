@@ -0,0 +1,119 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"*.tmp", "a.tmp", true},
+		{"*.tmp", "dir/sub/a.tmp", true},
+		{"*.tmp", "a.tmpx", false},
+		{"dir/*.log", "dir/a.log", true},
+		{"dir/*.log", "dir/sub/a.log", false},
+		{"dir/**.log", "dir/sub/a.log", true},
+		{"dir/**", "dir/a/b/c", true},
+		{"dir/**", "other/dir/a", false},
+		{"?.txt", "a.txt", true},
+		{"?.txt", "ab.txt", false},
+		{"a+b.txt", "a+b.txt", true},
+		{"a+b.txt", "aab.txt", false},
+	}
+	for _, test := range tests {
+		re, err := GlobToRegexp(test.pattern)
+		if err != nil {
+			t.Fatalf("%s: %s", test.pattern, err)
+		}
+		if re.MatchString(test.path) != test.match {
+			t.Errorf("%s against %s: got %v, want %v", test.pattern, test.path, !test.match, test.match)
+		}
+	}
+}
+
+func TestPlanSync(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	earlier := now.Add(-time.Hour)
+	local := func(size int64, modified time.Time) *LocalFile {
+		return &LocalFile{Size: size, ModTime: modified}
+	}
+	object := func(size int64, etag string, modified time.Time) *s3.Object {
+		return &s3.Object{Size: aws.Int64(size), ETag: aws.String(etag), LastModified: aws.Time(modified)}
+	}
+	synced := map[string]SyncStateEntry{
+		"same":   {Size: 1, ModTime: earlier.Unix(), ETag: "e1"},
+		"gone":   {Size: 1, ModTime: earlier.Unix(), ETag: "e1"},
+		"edited": {Size: 1, ModTime: earlier.Unix(), ETag: "e1"},
+		"both":   {Size: 1, ModTime: earlier.Unix(), ETag: "e1"},
+	}
+	tests := []struct {
+		name      string
+		mode      string
+		delete    bool
+		diff      FileDiff
+		actions   []string
+		unchanged int
+		conflicts []string
+	}{
+		{"upload new", SYNC_MODE_UPLOAD, false, FileDiff{RelPath: "new", Status: DIFF_ONLY_LOCAL, Local: local(1, now)}, []string{SYNC_ACTION_UPLOAD}, 0, nil},
+		{"upload keeps remote only", SYNC_MODE_UPLOAD, false, FileDiff{RelPath: "r", Status: DIFF_ONLY_REMOTE, Object: object(1, "e", now)}, nil, 0, nil},
+		{"upload deletes remote only", SYNC_MODE_UPLOAD, true, FileDiff{RelPath: "r", Status: DIFF_ONLY_REMOTE, Object: object(1, "e", now)}, []string{SYNC_ACTION_DELETE_REMOTE}, 0, nil},
+		{"upload same", SYNC_MODE_UPLOAD, true, FileDiff{RelPath: "s", Status: DIFF_SAME, Local: local(1, now), Object: object(1, "e", now)}, nil, 1, nil},
+		{"download new", SYNC_MODE_DOWNLOAD, false, FileDiff{RelPath: "r", Status: DIFF_ONLY_REMOTE, Object: object(1, "e", now)}, []string{SYNC_ACTION_DOWNLOAD}, 0, nil},
+		{"download remote newer", SYNC_MODE_DOWNLOAD, false, FileDiff{RelPath: "s", Status: DIFF_SAME, RemoteNewer: true, Local: local(1, earlier), Object: object(1, "e", now)}, []string{SYNC_ACTION_DOWNLOAD}, 0, nil},
+		{"download deletes local only", SYNC_MODE_DOWNLOAD, true, FileDiff{RelPath: "l", Status: DIFF_ONLY_LOCAL, Local: local(1, now)}, []string{SYNC_ACTION_DELETE_LOCAL}, 0, nil},
+		{"mirror unchanged", SYNC_MODE_MIRROR, true, FileDiff{RelPath: "same", Status: DIFF_CHANGED, Local: local(1, earlier), Object: object(1, "e1", now)}, nil, 1, nil},
+		{"mirror deleted remotely", SYNC_MODE_MIRROR, true, FileDiff{RelPath: "gone", Status: DIFF_ONLY_LOCAL, Local: local(1, earlier)}, []string{SYNC_ACTION_DELETE_LOCAL}, 0, nil},
+		{"mirror deleted remotely changed locally", SYNC_MODE_MIRROR, true, FileDiff{RelPath: "gone", Status: DIFF_ONLY_LOCAL, Local: local(2, now)}, []string{SYNC_ACTION_UPLOAD}, 0, []string{"gone"}},
+		{"mirror new local", SYNC_MODE_MIRROR, true, FileDiff{RelPath: "fresh", Status: DIFF_ONLY_LOCAL, Local: local(1, now)}, []string{SYNC_ACTION_UPLOAD}, 0, nil},
+		{"mirror changed locally", SYNC_MODE_MIRROR, false, FileDiff{RelPath: "edited", Status: DIFF_CHANGED, Local: local(2, now), Object: object(1, "e1", earlier)}, []string{SYNC_ACTION_UPLOAD}, 0, nil},
+		{"mirror changed remotely", SYNC_MODE_MIRROR, false, FileDiff{RelPath: "edited", Status: DIFF_CHANGED, Local: local(1, earlier), Object: object(2, "e2", now)}, []string{SYNC_ACTION_DOWNLOAD}, 0, nil},
+		{"mirror changed on both sides", SYNC_MODE_MIRROR, false, FileDiff{RelPath: "both", Status: DIFF_CHANGED, Local: local(2, now), Object: object(3, "e2", earlier)}, []string{SYNC_ACTION_UPLOAD}, 0, []string{"both"}},
+	}
+	for _, test := range tests {
+		options := SyncOptions{Mode: test.mode, Delete: test.delete}
+		actions, unchanged, conflicts := PlanSync([]FileDiff{test.diff}, "root", "prefix/", options, synced)
+		var names []string
+		for _, action := range actions {
+			names = append(names, action.Action)
+			if action.Key != "prefix/"+test.diff.RelPath || action.LocalPath != filepath.Join("root", test.diff.RelPath) {
+				t.Errorf("%s: wrong locations %s and %s", test.name, action.Key, action.LocalPath)
+			}
+		}
+		if !reflect.DeepEqual(names, test.actions) {
+			t.Errorf("%s: got actions %v, want %v", test.name, names, test.actions)
+		}
+		if unchanged != test.unchanged {
+			t.Errorf("%s: got %d unchanged, want %d", test.name, unchanged, test.unchanged)
+		}
+		if !reflect.DeepEqual(conflicts, test.conflicts) {
+			t.Errorf("%s: got conflicts %v, want %v", test.name, conflicts, test.conflicts)
+		}
+	}
+}
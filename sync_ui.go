@@ -0,0 +1,143 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gizak/termui"
+)
+
+func RenderSync(explorer *BucketExplorer, dir *Node, back func()) {
+
+	// Ask for the local directory and how to sync it
+
+	location := FormatS3Url(*explorer.bucket.bucket.Name, GetNodePrefix(dir))
+	RenderInputPrompt(fmt.Sprintf("Local directory to sync with %s", location), downloadDir, func(root string) {
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			RenderError(fmt.Sprintf("Not a directory: %s", root))
+			back()
+			return
+		}
+		modes := []string{
+			fmt.Sprintf("Upload: %s to %s", root, location),
+			fmt.Sprintf("Download: %s to %s", location, root),
+			"Mirror: both ways, the newer copy wins",
+		}
+		RenderChoicePrompt("Sync mode", modes, func(mode int) {
+			options := SyncOptions{Mode: GetSyncModes()[mode], Parallel: DEFAULT_SYNC_PARALLEL}
+			deletes := []string{"Keep files missing on the other side", "Propagate deletes"}
+			RenderChoicePrompt("Deletes", deletes, func(choice int) {
+				options.Delete = choice == 1
+				compare := []string{"Size and modification time", "Size and content (reads every file present on both sides)"}
+				RenderChoicePrompt("Compare by", compare, func(choice int) {
+					options.Checksum = choice == 1
+					RenderInputPrompt("Include globs (space separated, blank for everything)", "", func(include string) {
+						options.Include = strings.Fields(include)
+						RenderInputPrompt("Exclude globs (space separated, blank for none)", "", func(exclude string) {
+							options.Exclude = strings.Fields(exclude)
+							PreviewSync(explorer, dir, root, options, back)
+						}, back)
+					}, back)
+				}, back)
+			}, back)
+		}, back)
+	}, back)
+}
+
+func PreviewSync(explorer *BucketExplorer, dir *Node, root string, options SyncOptions, back func()) {
+
+	// Dry run first and show the plan, <y> carries it out
+
+	bucket := explorer.bucket
+	termui.Clear()
+	termui.Render(CreateStatusPrompt("Comparing files"))
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	options.DryRun = true
+	var lines []string
+	report, err := sess.RunSync(bucket, root, GetNodePrefix(dir), options, func(action SyncAction, err error, done int, total int) {
+		lines = append(lines, FormatSyncAction(action))
+	})
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	if len(lines) == 0 {
+		RenderTextViewer("Sync Plan", append([]string{"Nothing to sync", ""}, GetSyncReportLines(report)...), back)
+		return
+	}
+	lines = append(lines, "")
+	lines = append(lines, GetSyncReportLines(report)...)
+	RenderTextViewer("Sync Plan", lines, back, "<y> run sync")
+	termui.Handle("/sys/kbd/y", func(termui.Event) {
+		options.DryRun = false
+		ApplySync(explorer, dir, root, options, back)
+	})
+}
+
+func ApplySync(explorer *BucketExplorer, dir *Node, root string, options SyncOptions, back func()) {
+
+	// Run the sync with a progress bar and show the report
+
+	bucket := explorer.bucket
+	termui.Clear()
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	report, err := sess.RunSync(bucket, root, GetNodePrefix(dir), options, func(action SyncAction, err error, done int, total int) {
+		termui.Render(CreateProgressGauge("Syncing files", done, total))
+	})
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+	}
+	lines := GetSyncReportLines(report)
+	if len(report.Failures) > 0 {
+		lines = append(lines, "", "Failures:")
+		for _, failure := range report.Failures {
+			lines = append(lines, "  "+failure)
+		}
+	}
+
+	// Reload the bucket if the remote side changed
+
+	RenderTextViewer("Sync Report", lines, func() {
+		if report.Counts[SYNC_ACTION_UPLOAD]+report.Counts[SYNC_ACTION_DELETE_REMOTE] > 0 {
+			explorer.deferFunc()
+			LoadBucketExplorer(explorer.bucket, explorer.showDeleted)
+			return
+		}
+		back()
+	})
+}
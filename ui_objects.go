@@ -25,7 +25,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gizak/termui"
+	"github.com/nsf/termbox-go"
 )
 
 func RunUi() {
@@ -41,7 +43,7 @@ func RunUi() {
 
 	// Get an initial bucket listing
 
-	buckets, err := s3Session.GetBucketWithDisplayStrings()
+	buckets, failures, err := s3Session.GetBucketWithDisplayStrings()
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 		os.Exit(EXIT_FAILED_BUCKET_LISTING)
@@ -49,11 +51,26 @@ func RunUi() {
 
 	// Set the exit handler and load the main buckets screen
 
-	SetDefaultHandlers(func() { return })
-	RenderBucketListing(buckets)
+	RenderFreshBucketListing(buckets, failures)
 	termui.Loop()
 }
 
+func SuspendUi(runFunc func()) {
+
+	// Hand the terminal over to an external program and take it back after
+
+	log.Println("Suspending terminal UI")
+	termbox.Close()
+	runFunc()
+	err := termbox.Init()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err)
+		os.Exit(EXIT_FAILED_NO_TERMINAL)
+	}
+	termbox.Sync()
+	log.Println("Resumed terminal UI")
+}
+
 func HaveTermSpace(maxHeight int) bool {
 
 	// Determine if we have terminal space to render the desired objets height
@@ -98,16 +115,22 @@ func GetStringListHeight(strings []string) int {
 	return max
 }
 
-func RenderHelp() (p *termui.Par) {
+func RenderHelp(extraKeys ...string) (p *termui.Par) {
 
-	// Create a par for the default help window
+	// Create a par for the default help window plus any screen specific keys
 
 	arrows := "\u2195\ufe0f"
 	returnArrow := "\u21b2"
 	helpText := fmt.Sprintf("%v navigate - %v open - <q> quit - <b> back", arrows, returnArrow)
+	for _, key := range extraKeys {
+		helpText = fmt.Sprintf("%s - %s", helpText, key)
+	}
 	p = termui.NewPar(helpText)
 	p.Height = 3
 	p.Width = len(helpText) + 3
+	if p.Width > termui.TermWidth() {
+		p.Width = termui.TermWidth()
+	}
 	p.TextFgColor = termui.ColorWhite
 	p.BorderLabel = "Help"
 	p.BorderFg = termui.ColorCyan
@@ -170,6 +193,37 @@ func CreateFinishedDownloadPrompt(dest string) (p *termui.Par) {
 	return
 }
 
+func CreateStatusPrompt(msg string) (p *termui.Par) {
+
+	// Create a status line for the bottom of the screen
+
+	p = termui.NewPar(msg)
+	p.Height = 5
+	p.Width = termui.TermWidth() - RIGHT_BUFFER
+	p.TextFgColor = termui.ColorWhite
+	p.Border = false
+	p.Y = termui.TermHeight() - 10
+	return
+}
+
+func CreateProgressGauge(label string, done int, total int) (g *termui.Gauge) {
+
+	// Create a progress bar for long running bulk actions
+
+	g = termui.NewGauge()
+	if total > 0 {
+		g.Percent = done * 100 / total
+	}
+	g.Label = fmt.Sprintf("%d/%d ({{percent}}%%)", done, total)
+	g.Height = 3
+	g.Width = termui.TermWidth() - RIGHT_BUFFER
+	g.BarColor = termui.ColorCyan
+	g.BorderLabel = label
+	g.BorderFg = termui.ColorCyan
+	g.Y = termui.TermHeight() / 3
+	return
+}
+
 func CreateBucketList(buckets []BucketWithDisplay, selection int) *termui.List {
 
 	// Create a list of buckets
@@ -200,6 +254,16 @@ func CreateBucketList(buckets []BucketWithDisplay, selection int) *termui.List {
 	return ls
 }
 
+func GetStorageClassDisplay(object *s3.Object) string {
+
+	// Listings leave the storage class out for STANDARD in some cases
+
+	if object.StorageClass == nil || *object.StorageClass == "" {
+		return s3.StorageClassStandard
+	}
+	return *object.StorageClass
+}
+
 func TruncateFilename(filename string) (truncated string, space int) {
 
 	// truncate a filename and determine space until size
@@ -214,14 +278,21 @@ func TruncateFilename(filename string) (truncated string, space int) {
 }
 
 func GetDirectoryDisplayListing(objects []string, selection int) (listing []string, err error) {
+	return GetStyledDisplayListing(objects, nil, selection)
+}
+
+func GetStyledDisplayListing(objects []string, styles []string, selection int) (listing []string, err error) {
 
-	// hilight the currently selected entry
+	// hilight the currently selected entry, and style any others that
+	// have a style set (e.g. "fg-black,fg-bold" to grey them out)
 
 	var index int
 	index = 0
 	for _, obj := range objects {
 		if index == selection {
 			listing = append(listing, fmt.Sprintf("[[%v] %s](bg-blue)", index, obj))
+		} else if index < len(styles) && styles[index] != "" {
+			listing = append(listing, fmt.Sprintf("[[%v] %s](%s)", index, obj, styles[index]))
 		} else {
 			listing = append(listing, fmt.Sprintf("[%v] %s", index, obj))
 		}
@@ -248,22 +319,57 @@ func GetDirectoryDisplayListing(objects []string, selection int) (listing []stri
 	return
 }
 
-func CreateDirectoryList(title string, nodes []*Node, selection int) *termui.List {
+func CreateStringList(title string, lines []string, selection int) *termui.List {
+
+	// Create a selectable list of preformatted lines
+
+	listing, err := GetDirectoryDisplayListing(lines, selection)
+	if err != nil {
+		RenderError(err.Error())
+		return &termui.List{}
+	}
+	ls := termui.NewList()
+	ls.Items = listing
+	ls.ItemFgColor = termui.ColorYellow
+	ls.BorderLabel = title
+	ls.Height = GetStringListHeight(lines)
+	ls.Width = termui.TermWidth() - RIGHT_BUFFER
+	ls.Y = 0
+	return ls
+}
+
+func CreateDirectoryList(title string, nodes []*Node, selection int, marked map[*Node]bool) *termui.List {
 
 	var displayStrings []string
+	var styles []string
 
 	for _, node := range nodes {
 		var display string
-		if !node.Info.IsDir {
+		if !node.Info.IsDir && node.S3Object == nil {
+
+			// Local files have no storage class
+
+			file, space := TruncateFilename(node.DisplayString)
+			display = fmt.Sprintf("%s%s%-10v", file, strings.Repeat(" ", space), ByteFormat(float64(node.Info.Size), 1))
+		} else if !node.Info.IsDir {
 			file, space := TruncateFilename(node.DisplayString)
-			display = fmt.Sprintf("%s%s%v", file, strings.Repeat(" ", space), ByteFormat(float64(*node.S3Object.Size), 1))
+			display = fmt.Sprintf("%s%s%-10v %s", file, strings.Repeat(" ", space), ByteFormat(float64(*node.S3Object.Size), 1), GetStorageClassDisplay(node.S3Object))
 		} else {
 			display = node.DisplayString
 		}
+		if marked[node] {
+			display = "* " + display
+		}
+		style := ""
+		if node.IsDeleted {
+			display = display + " (deleted)"
+			style = DELETED_NODE_STYLE
+		}
 		displayStrings = append(displayStrings, display)
+		styles = append(styles, style)
 	}
 
-	listing, err := GetDirectoryDisplayListing(displayStrings, selection)
+	listing, err := GetStyledDisplayListing(displayStrings, styles, selection)
 	if err != nil {
 		RenderError(err.Error())
 		return &termui.List{}
@@ -0,0 +1,227 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gizak/termui"
+)
+
+func CreateInputPar(label string, input string) (p *termui.Par) {
+
+	// Create a par showing the text typed so far
+
+	p = termui.NewPar(fmt.Sprintf("%s_", input))
+	p.Height = 3
+	p.Width = termui.TermWidth() - RIGHT_BUFFER
+	p.TextFgColor = termui.ColorWhite
+	p.BorderLabel = label
+	p.BorderFg = termui.ColorCyan
+	p.Y = termui.TermHeight() / 3
+	return
+}
+
+func RenderInputPrompt(label string, initial string, onSubmit func(string), onCancel func()) {
+
+	// Read a line of text from the keyboard.
+	// Every key types, so there is no quit handler while the prompt is up.
+
+	log.Printf("Rendering input prompt: %s\n", label)
+	input := []rune(initial)
+	termui.ResetHandlers()
+	termui.Render(CreateInputPar(label, string(input)), RenderPromptHelp())
+
+	termui.Handle("/sys/kbd", func(e termui.Event) {
+		key := e.Data.(termui.EvtKbd).KeyStr
+		switch key {
+		case "<enter>":
+			log.Printf("Input submitted for %s: %s\n", label, string(input))
+			onSubmit(string(input))
+			return
+		case "<escape>":
+			log.Printf("Input cancelled for %s\n", label)
+			onCancel()
+			return
+		case "C-8", "<backspace>":
+			if len(input) > 0 {
+				input = input[:len(input)-1]
+			}
+		case "<space>":
+			input = append(input, ' ')
+		default:
+			// Only take printable single characters
+			if len([]rune(key)) == 1 {
+				input = append(input, []rune(key)...)
+			}
+		}
+		termui.Render(CreateInputPar(label, string(input)))
+	})
+}
+
+func RenderChoicePrompt(label string, options []string, onSelect func(int), onCancel func()) {
+
+	// Pick one of a list of options
+
+	log.Printf("Rendering choice prompt: %s\n", label)
+	selection := 0
+	termui.ResetHandlers()
+	termui.Render(CreateChoiceList(label, options, selection), RenderPromptHelp())
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			termui.Render(CreateChoiceList(label, options, selection))
+		}
+	})
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(options)-1 {
+			selection += 1
+			termui.Render(CreateChoiceList(label, options, selection))
+		}
+	})
+	termui.Handle("/sys/kbd/<enter>", func(termui.Event) {
+		log.Printf("Selected option for %s: %s\n", label, options[selection])
+		onSelect(selection)
+	})
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		onCancel()
+	})
+}
+
+func CreateChoiceList(label string, options []string, selection int) *termui.List {
+
+	// Create a list of options with the selection highlighted
+
+	listing, err := GetDirectoryDisplayListing(options, selection)
+	if err != nil {
+		RenderError(err.Error())
+		return &termui.List{}
+	}
+	ls := termui.NewList()
+	ls.Items = listing
+	ls.ItemFgColor = termui.ColorYellow
+	ls.BorderLabel = label
+	ls.BorderFg = termui.ColorCyan
+	ls.Height = GetStringListHeight(options)
+	ls.Width = termui.TermWidth() - RIGHT_BUFFER
+	ls.Y = 0
+	return ls
+}
+
+func RenderConfirmPrompt(label string, message string, onYes func(), onNo func()) {
+
+	// Ask a yes/no question
+
+	log.Printf("Rendering confirm prompt: %s\n", label)
+	p := RenderMessage(label, fmt.Sprintf("%s [y/n]", message))
+	p.Y = termui.TermHeight() / 3
+	termui.ResetHandlers()
+	termui.Render(p)
+
+	termui.Handle("/sys/kbd/y", func(termui.Event) {
+		onYes()
+	})
+	termui.Handle("/sys/kbd/n", func(termui.Event) {
+		onNo()
+	})
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		onNo()
+	})
+}
+
+func RenderPromptHelp() (p *termui.Par) {
+
+	// Help window for prompts
+
+	returnArrow := "\u21b2"
+	helpText := fmt.Sprintf("%v accept - <esc> cancel", returnArrow)
+	p = termui.NewPar(helpText)
+	p.Height = 3
+	p.Width = len(helpText) + 3
+	p.TextFgColor = termui.ColorWhite
+	p.BorderLabel = "Help"
+	p.BorderFg = termui.ColorCyan
+	p.Y = termui.TermHeight() - 5
+	return
+}
+
+func CreateTextViewer(title string, lines []string, offset int) *termui.List {
+
+	// Create a list showing a window of lines starting at offset
+
+	height := termui.TermHeight() - LOWER_BUFFER
+	end := offset + height - 2
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if end < offset {
+		end = offset
+	}
+	ls := termui.NewList()
+	ls.Items = lines[offset:end]
+	ls.ItemFgColor = termui.ColorWhite
+	ls.BorderLabel = title
+	ls.BorderFg = termui.ColorCyan
+	ls.Height = height
+	ls.Width = termui.TermWidth() - RIGHT_BUFFER
+	ls.Y = 0
+	return ls
+}
+
+func RenderTextViewer(title string, lines []string, onClose func(), extraKeys ...string) {
+
+	// Show scrollable text, <b> or <esc> closes it and returns to the caller's
+	// screen (which owns the quit handler). Callers may add handlers for
+	// extraKeys after this returns.
+
+	log.Printf("Rendering text viewer: %s\n", title)
+	offset := 0
+	page := termui.TermHeight() - LOWER_BUFFER - 2
+	if page < 1 {
+		page = 1
+	}
+	maxOffset := len(lines) - page
+	if maxOffset < 0 {
+		maxOffset = 0
+	}
+
+	termui.ResetHandlers()
+	termui.Clear()
+	termui.Render(CreateTextViewer(title, lines, offset), RenderHelp(extraKeys...))
+	SetBackHandler(onClose)
+
+	scroll := func(by int) {
+		offset += by
+		if offset > maxOffset {
+			offset = maxOffset
+		}
+		if offset < 0 {
+			offset = 0
+		}
+		termui.Clear()
+		termui.Render(CreateTextViewer(title, lines, offset), RenderHelp(extraKeys...))
+	}
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) { scroll(-1) })
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) { scroll(1) })
+	termui.Handle("/sys/kbd/<previous>", func(termui.Event) { scroll(-page) })
+	termui.Handle("/sys/kbd/<next>", func(termui.Event) { scroll(page) })
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) { onClose() })
+}
@@ -0,0 +1,291 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type ObjectVersion struct {
+	Key            string
+	VersionId      string
+	ETag           string
+	StorageClass   string
+	Size           int64
+	LastModified   time.Time
+	IsLatest       bool
+	IsDeleteMarker bool
+}
+
+func (s S3Session) GetObjectVersions(bucket BucketWithDisplay, key string) (versions []ObjectVersion, err error) {
+
+	// List every version and delete marker for a single key, newest first
+
+	all, err := s.GetPrefixVersions(bucket, key)
+	if err != nil {
+		return
+	}
+	for _, version := range all {
+		if version.Key == key {
+			versions = append(versions, version)
+		}
+	}
+	return
+}
+
+func (s S3Session) GetPrefixVersions(bucket BucketWithDisplay, prefix string) (versions []ObjectVersion, err error) {
+
+	// List every version and delete marker under a prefix, newest first per key
+
+	log.Printf("Listing versions for s3://%s/%s\n", *bucket.bucket.Name, prefix)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	err = s.S3Service.ListObjectVersionsPages(&s3.ListObjectVersionsInput{
+		Bucket: bucket.bucket.Name,
+		Prefix: aws.String(prefix),
+	},
+		func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+			for _, v := range page.Versions {
+				versions = append(versions, ObjectVersion{
+					Key:          aws.StringValue(v.Key),
+					VersionId:    aws.StringValue(v.VersionId),
+					ETag:         aws.StringValue(v.ETag),
+					StorageClass: aws.StringValue(v.StorageClass),
+					Size:         aws.Int64Value(v.Size),
+					LastModified: aws.TimeValue(v.LastModified),
+					IsLatest:     aws.BoolValue(v.IsLatest),
+				})
+			}
+			for _, m := range page.DeleteMarkers {
+				versions = append(versions, ObjectVersion{
+					Key:            aws.StringValue(m.Key),
+					VersionId:      aws.StringValue(m.VersionId),
+					LastModified:   aws.TimeValue(m.LastModified),
+					IsLatest:       aws.BoolValue(m.IsLatest),
+					IsDeleteMarker: true,
+				})
+			}
+			return true
+		})
+	SortObjectVersions(versions)
+	log.Printf("Found %d versions\n", len(versions))
+	return
+}
+
+func SortObjectVersions(versions []ObjectVersion) {
+
+	// By key, newest first. Versions written in the same second are
+	// ordered by which one S3 says is the latest.
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versions[i].Key != versions[j].Key {
+			return versions[i].Key < versions[j].Key
+		}
+		if !versions[i].LastModified.Equal(versions[j].LastModified) {
+			return versions[i].LastModified.After(versions[j].LastModified)
+		}
+		return versions[i].IsLatest && !versions[j].IsLatest
+	})
+}
+
+func CopySourcePath(bucket string, key string, versionId string) string {
+
+	// Build a url encoded CopySource, optionally pinned to a version
+
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	source := url.PathEscape(bucket) + "/" + strings.Join(segments, "/")
+	if versionId != "" {
+		source = source + "?versionId=" + url.QueryEscape(versionId)
+	}
+	return source
+}
+
+func (s S3Session) RestoreObjectVersion(bucket BucketWithDisplay, version ObjectVersion) (object *s3.Object, err error) {
+
+	// Copy an older version over the current one, making it the latest.
+	// The copy keeps the version's storage class, which otherwise resets
+	// to STANDARD.
+
+	log.Printf("Restoring %s to version %s\n", version.Key, version.VersionId)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	if version.Size > MAX_COPY_OBJECT_SIZE {
+		err = fmt.Errorf("%s version %s is too large to restore with a server side copy", version.Key, version.VersionId)
+		return
+	}
+	storageClass := version.StorageClass
+	if storageClass == "" {
+		storageClass = s3.StorageClassStandard
+	}
+	out, err := s.S3Service.CopyObject(&s3.CopyObjectInput{
+		Bucket:       bucket.bucket.Name,
+		Key:          aws.String(version.Key),
+		CopySource:   aws.String(CopySourcePath(*bucket.bucket.Name, version.Key, version.VersionId)),
+		StorageClass: aws.String(storageClass),
+	})
+	if err != nil {
+		return
+	}
+
+	// Hand back an object describing the new latest version
+
+	object = &s3.Object{
+		Key:          aws.String(version.Key),
+		Size:         aws.Int64(version.Size),
+		StorageClass: aws.String(storageClass),
+	}
+	if out.CopyObjectResult != nil {
+		object.ETag = out.CopyObjectResult.ETag
+		object.LastModified = out.CopyObjectResult.LastModified
+	}
+	return
+}
+
+func (s S3Session) DeleteObjectVersion(bucket BucketWithDisplay, version ObjectVersion) (err error) {
+
+	// Permanently delete a single version or delete marker
+
+	log.Printf("Permanently deleting %s version %s\n", version.Key, version.VersionId)
+	if err = s.RequireS3(); err != nil {
+		return
+	}
+	_, err = s.S3Service.DeleteObject(&s3.DeleteObjectInput{
+		Bucket:    bucket.bucket.Name,
+		Key:       aws.String(version.Key),
+		VersionId: aws.String(version.VersionId),
+	})
+	return
+}
+
+func (s S3Session) GetBucketObjectsWithDeleted(bucket BucketWithDisplay) (objects []*s3.Object, deleted map[string]bool, err error) {
+
+	// List a bucket from its versions so that keys whose latest version is a
+	// delete marker show up too. Deleted keys are represented by their most
+	// recent real version.
+
+	versions, err := s.GetPrefixVersions(bucket, "")
+	if err != nil {
+		return
+	}
+	deleted = make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, version := range versions {
+		if version.IsDeleteMarker {
+			// A key is deleted when its latest version is a delete marker
+			if version.IsLatest {
+				deleted[version.Key] = true
+			}
+			continue
+		}
+		if seen[version.Key] {
+			continue
+		}
+		seen[version.Key] = true
+		objects = append(objects, &s3.Object{
+			Key:          aws.String(version.Key),
+			ETag:         aws.String(version.ETag),
+			Size:         aws.Int64(version.Size),
+			StorageClass: aws.String(version.StorageClass),
+			LastModified: aws.Time(version.LastModified),
+		})
+	}
+
+	// Keys with nothing but delete markers have no content to show
+
+	for key := range deleted {
+		if !seen[key] {
+			delete(deleted, key)
+		}
+	}
+	log.Printf("Found %d objects, %d deleted\n", len(objects), len(deleted))
+	return
+}
+
+func GetUndeleteMarkers(versions []ObjectVersion) (markers []*s3.ObjectIdentifier) {
+
+	// For each key, the delete markers newer than its latest real version
+	// are what hides it. Keys with no real version are left alone.
+
+	var pending []*s3.ObjectIdentifier
+	current := ""
+	found := false
+	for _, version := range versions {
+		if version.Key != current {
+			current = version.Key
+			pending = nil
+			found = false
+		}
+		if found {
+			continue
+		}
+		if version.IsDeleteMarker {
+			pending = append(pending, &s3.ObjectIdentifier{
+				Key:       aws.String(version.Key),
+				VersionId: aws.String(version.VersionId),
+			})
+			continue
+		}
+		found = true
+		markers = append(markers, pending...)
+	}
+	return
+}
+
+func (s S3Session) UndeleteObjects(bucket BucketWithDisplay, prefix string, exact bool) (undeleted int, failures []string, err error) {
+
+	// Remove the delete markers hiding a key, or every deleted key under a
+	// prefix, bringing back their latest real version
+
+	versions, err := s.GetPrefixVersions(bucket, prefix)
+	if err != nil {
+		return
+	}
+	if exact {
+		var matched []ObjectVersion
+		for _, version := range versions {
+			if version.Key == prefix {
+				matched = append(matched, version)
+			}
+		}
+		versions = matched
+	}
+
+	markers := GetUndeleteMarkers(versions)
+	keys := make(map[string]bool)
+	for _, marker := range markers {
+		keys[*marker.Key] = true
+	}
+	log.Printf("Removing %d delete markers for %d keys\n", len(markers), len(keys))
+
+	failures, err = s.DeleteObjectBatch(bucket, markers)
+	undeleted = len(keys)
+	return
+}
@@ -0,0 +1,72 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+func TestSortObjectVersionsUndelete(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		versions []ObjectVersion
+		markers  []string
+	}{
+		{
+			name: "marker written in the same second as the version",
+			versions: []ObjectVersion{
+				{Key: "a", VersionId: "v1", LastModified: now},
+				{Key: "a", VersionId: "m1", LastModified: now, IsLatest: true, IsDeleteMarker: true},
+			},
+			markers: []string{"m1"},
+		},
+		{
+			name: "version written in the same second as the marker",
+			versions: []ObjectVersion{
+				{Key: "a", VersionId: "m1", LastModified: now, IsDeleteMarker: true},
+				{Key: "a", VersionId: "v1", LastModified: now, IsLatest: true},
+			},
+		},
+		{
+			name: "older versions and other keys",
+			versions: []ObjectVersion{
+				{Key: "b", VersionId: "v2", LastModified: now.Add(-time.Hour)},
+				{Key: "a", VersionId: "v1", LastModified: now.Add(-time.Hour)},
+				{Key: "b", VersionId: "m2", LastModified: now, IsLatest: true, IsDeleteMarker: true},
+				{Key: "c", VersionId: "m3", LastModified: now, IsLatest: true, IsDeleteMarker: true},
+				{Key: "a", VersionId: "v0", LastModified: now.Add(-2 * time.Hour)},
+			},
+			markers: []string{"m2"},
+		},
+	}
+	for _, test := range tests {
+		SortObjectVersions(test.versions)
+		var markers []string
+		for _, marker := range GetUndeleteMarkers(test.versions) {
+			markers = append(markers, aws.StringValue(marker.VersionId))
+		}
+		if !reflect.DeepEqual(markers, test.markers) {
+			t.Errorf("%s: got markers %v, want %v", test.name, markers, test.markers)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+/**
+This file is part of s3explorer.
+
+s3explorer is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+s3explorer is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with s3explorer.  If not, see <https://www.gnu.org/licenses/>.
+**/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gizak/termui"
+)
+
+func RenderObjectVersions(bucket BucketWithDisplay, node *Node, back func()) {
+
+	// Fetch the version history for a file node and show it
+
+	key := *node.S3Object.Key
+	termui.Render(CreateStatusPrompt(fmt.Sprintf("Listing versions of %s", key)))
+
+	sess, err := InitBucketSession(bucket)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+
+	versions, err := sess.GetObjectVersions(bucket, key)
+	if err != nil {
+		log.Println(err)
+		RenderError(err.Error())
+		back()
+		return
+	}
+	if len(versions) == 0 {
+		RenderError(fmt.Sprintf("No versions found for %s", key))
+		back()
+		return
+	}
+
+	RenderObjectVersionListing(bucket, node, sess, versions, 0, back)
+}
+
+func FormatObjectVersion(version ObjectVersion) string {
+
+	// One line summary of a version or delete marker
+
+	var detail string
+	if version.IsDeleteMarker {
+		detail = fmt.Sprintf("%-10s  %-34s", "-", "(delete marker)")
+	} else {
+		detail = fmt.Sprintf("%-10s  %-34s", ByteFormat(float64(version.Size), 1), version.ETag)
+	}
+	latest := ""
+	if version.IsLatest {
+		latest = " [latest]"
+	}
+	return fmt.Sprintf("%s  %s  %s%s",
+		version.LastModified.Local().Format("2006-01-02 15:04:05"),
+		detail,
+		version.VersionId,
+		latest,
+	)
+}
+
+func CreateVersionList(title string, versions []ObjectVersion, selection int) *termui.List {
+
+	// Create a list of versions with the selection highlighted
+
+	var displayStrings []string
+	for _, version := range versions {
+		displayStrings = append(displayStrings, FormatObjectVersion(version))
+	}
+
+	listing, err := GetDirectoryDisplayListing(displayStrings, selection)
+	if err != nil {
+		RenderError(err.Error())
+		return &termui.List{}
+	}
+
+	ls := termui.NewList()
+	ls.Items = listing
+	ls.ItemFgColor = termui.ColorYellow
+	ls.BorderLabel = title
+	ls.Height = GetStringListHeight(displayStrings)
+	ls.Width = termui.TermWidth() - RIGHT_BUFFER
+	ls.Y = 0
+	return ls
+}
+
+func GetVersionDownloadPath(version ObjectVersion) string {
+
+	// Downloads of a version get its id before the extension so they don't
+	// clobber the current one
+
+	base := path.Base(version.Key)
+	ext := filepath.Ext(base)
+	name := fmt.Sprintf("%s.%s%s", strings.TrimSuffix(base, ext), version.VersionId, ext)
+	return filepath.Join(downloadDir, name)
+}
+
+func RenderObjectVersionListing(bucket BucketWithDisplay, node *Node, sess S3Session, versions []ObjectVersion, selection int, back func()) {
+
+	// Render the versions of a key with actions for each
+
+	title := fmt.Sprintf("Versions: s3://%s/%s", *bucket.bucket.Name, *node.S3Object.Key)
+	help := RenderHelp("<o> preview", "<d> download", "<r> restore", "<x> delete")
+	render := func() {
+		termui.Render(CreateVersionList(title, versions, selection), help)
+	}
+	reload := func() {
+		RenderObjectVersions(bucket, node, back)
+	}
+	stay := func() {
+		RenderObjectVersionListing(bucket, node, sess, versions, selection, back)
+	}
+
+	termui.ResetHandlers()
+	termui.Clear()
+	render()
+	SetDefaultHandlers(func() { return })
+	SetBackHandler(back)
+
+	termui.Handle("/sys/kbd/<escape>", func(termui.Event) {
+		back()
+	})
+
+	// Up key moves up
+
+	termui.Handle("/sys/kbd/<up>", func(termui.Event) {
+		if selection > 0 {
+			selection -= 1
+			render()
+		}
+	})
+
+	// Down key moves down
+
+	termui.Handle("/sys/kbd/<down>", func(termui.Event) {
+		if selection < len(versions)-1 {
+			selection += 1
+			render()
+		}
+	})
+
+	// o (or enter) previews a version with the configured opener
+
+	preview := func(termui.Event) {
+		version := versions[selection]
+		if version.IsDeleteMarker {
+			RenderError("A delete marker has no content")
+			return
+		}
+		status := OpenObjectExternally(bucket, version.Key, version.VersionId)
+		stay()
+		if status != "" {
+			termui.Render(CreateStatusPrompt(status))
+		}
+	}
+	termui.Handle("/sys/kbd/o", preview)
+	termui.Handle("/sys/kbd/<enter>", preview)
+
+	// d downloads a version next to the working directory
+
+	termui.Handle("/sys/kbd/d", func(termui.Event) {
+		version := versions[selection]
+		if version.IsDeleteMarker {
+			RenderError("A delete marker has no content")
+			return
+		}
+		dest := GetVersionDownloadPath(version)
+		termui.Render(CreateDownloadPrompt(dest))
+		err := sess.DownloadObjectVersion(bucket, version.Key, version.VersionId, dest)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			return
+		}
+		termui.Render(CreateFinishedDownloadPrompt(dest))
+	})
+
+	// r restores a version by copying it over the latest
+
+	termui.Handle("/sys/kbd/r", func(termui.Event) {
+		version := versions[selection]
+		if version.IsDeleteMarker {
+			RenderError("Cannot restore a delete marker")
+			return
+		}
+		if version.IsLatest {
+			RenderError("This is already the latest version")
+			return
+		}
+		msg := fmt.Sprintf("Restore %s to version %s?", version.Key, version.VersionId)
+		RenderConfirmPrompt("Restore Version", msg, func() {
+			object, err := sess.RestoreObjectVersion(bucket, version)
+			if err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				stay()
+				return
+			}
+			node.S3Object = object
+			reload()
+		}, stay)
+	})
+
+	// x permanently deletes a version
+
+	termui.Handle("/sys/kbd/x", func(termui.Event) {
+		version := versions[selection]
+		msg := fmt.Sprintf("PERMANENTLY delete %s version %s?", version.Key, version.VersionId)
+		RenderConfirmPrompt("Delete Version", msg, func() {
+			err := sess.DeleteObjectVersion(bucket, version)
+			if err != nil {
+				log.Println(err)
+				RenderError(err.Error())
+				stay()
+				return
+			}
+			reload()
+		}, stay)
+	})
+}
+
+func RenderUndelete(explorer *BucketExplorer, node *Node, back func()) {
+
+	// Confirm, then remove the delete markers for a file or every deleted
+	// file under a directory
+
+	if !node.IsDeleted && !node.Info.IsDir {
+		RenderError("This object is not deleted")
+		return
+	}
+
+	var prefix, msg string
+	exact := !node.Info.IsDir
+	if exact {
+		prefix = *node.S3Object.Key
+		msg = fmt.Sprintf("Undelete %s?", prefix)
+	} else {
+		prefix = GetNodePrefix(node)
+		msg = fmt.Sprintf("Undelete every deleted object under s3://%s/%s?", *explorer.bucket.bucket.Name, prefix)
+	}
+
+	RenderConfirmPrompt("Undelete", msg, func() {
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Undeleting %s", prefix)))
+		sess, err := InitBucketSession(explorer.bucket)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+
+		undeleted, failures, err := sess.UndeleteObjects(explorer.bucket, prefix, exact)
+		if err != nil {
+			log.Println(err)
+			RenderError(err.Error())
+			back()
+			return
+		}
+		if len(failures) > 0 {
+			lines := append([]string{fmt.Sprintf("%d failed to undelete:", len(failures))}, failures...)
+			RenderTextViewer("Undelete Failures", lines, back)
+			return
+		}
+
+		ClearDeletedNodes(node)
+		back()
+		termui.Render(CreateStatusPrompt(fmt.Sprintf("Undeleted %d objects", undeleted)))
+	}, back)
+}